@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"fmt"
+	"geofilter/proxy"
+	"math/rand"
+	"net"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const iterationsFlag = "n"
+
+var benchCmd = &cobra.Command{
+	Use:     "bench",
+	Short:   "Measure lookup throughput of a GeoIP database",
+	Example: "geofilter bench --database=GeoLite2-Country.mmdb --n 1000000",
+	RunE:    runBench,
+}
+
+// randomIP returns a random IPv4 or IPv6 address, the families are mixed evenly.
+func randomIP(rnd *rand.Rand) net.IP {
+	size := net.IPv4len
+	if rnd.Intn(2) == 1 {
+		size = net.IPv6len
+	}
+
+	ip := make(net.IP, size)
+	rnd.Read(ip)
+	return ip
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+func runBench(cmd *cobra.Command, _ []string) error {
+	database, _ := cmd.Flags().GetString(databaseFlag)
+	n, _ := cmd.Flags().GetInt(iterationsFlag)
+
+	if n <= 0 {
+		return errors.Errorf("--%s must be positive", iterationsFlag)
+	}
+
+	db, err := proxy.OpenDatabase(database)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ips := make([]net.IP, n)
+	for i := range ips {
+		ips[i] = randomIP(rnd)
+	}
+	latencies := make([]time.Duration, n)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	unresolved := 0
+	started := time.Now()
+	for i, ip := range ips {
+		lookupStarted := time.Now()
+		if _, err := db.Country(ip); err != nil {
+			unresolved++
+		}
+		latencies[i] = time.Since(lookupStarted)
+	}
+	elapsed := time.Since(started)
+
+	runtime.ReadMemStats(&after)
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	out := cmd.OutOrStdout()
+	_, _ = fmt.Fprintf(out, "lookups:     %d (%d failed)\n", n, unresolved)
+	_, _ = fmt.Fprintf(out, "elapsed:     %v\n", elapsed)
+	_, _ = fmt.Fprintf(out, "throughput:  %.0f lookups/sec\n", float64(n)/elapsed.Seconds())
+	_, _ = fmt.Fprintf(out, "p50:         %v\n", percentile(latencies, 0.50))
+	_, _ = fmt.Fprintf(out, "p99:         %v\n", percentile(latencies, 0.99))
+	_, _ = fmt.Fprintf(out, "allocated:   %d bytes/lookup\n", (after.TotalAlloc-before.TotalAlloc)/uint64(n))
+	_, _ = fmt.Fprintf(out, "heap in use: %d bytes\n", after.HeapInuse)
+
+	return nil
+}
+
+func addBenchFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP(databaseFlag, "d", getDefaultDatabase(), "Path to MaxMind database, defaults to $"+databaseEnv)
+	cmd.Flags().Int(iterationsFlag, 1000000, "Number of lookups")
+
+	_ = cmd.MarkFlagFilename(databaseFlag, "mmdb")
+}
+
+func init() {
+	addBenchFlags(benchCmd)
+
+	startProxyCmd.AddCommand(benchCmd)
+}
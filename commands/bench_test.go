@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"compress/gzip"
+	"geofilter/internal/mmdbtest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func runBenchCmd(args ...string) (string, error) {
+	cmd := &cobra.Command{Use: "bench", RunE: runBench, SilenceUsage: true, SilenceErrors: true}
+	addBenchFlags(cmd)
+
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(args)
+
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func writeBenchDatabase(t *testing.T, compress bool) string {
+	t.Helper()
+
+	data, err := mmdbtest.Build("GeoLite2-Country", map[string]interface{}{
+		"0.0.0.0/1": mmdbtest.Country("US"),
+		"8000::/1":  mmdbtest.Country("DE"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write(data)
+		_ = gz.Close()
+		data = buf.Bytes()
+	}
+
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	path := filepath.Join(dir, "country.mmdb")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestBench(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		out, err := runBenchCmd("--database", writeBenchDatabase(t, compress), "--n", "100")
+		if err != nil {
+			t.Fatalf("gzip %v: %v", compress, err)
+		}
+
+		for _, expected := range []string{"lookups:     100 (0 failed)", "throughput:", "p50:", "p99:"} {
+			if !strings.Contains(out, expected) {
+				t.Errorf("gzip %v: '%s' is not reported:\n%s", compress, expected, out)
+			}
+		}
+	}
+}
+
+func TestBenchErrors(t *testing.T) {
+	if _, err := runBenchCmd("--database", writeBenchDatabase(t, false), "--n", "0"); err == nil {
+		t.Error("zero iterations are accepted")
+	}
+	if _, err := runBenchCmd("--database", "missing.mmdb", "--n", "10"); err == nil {
+		t.Error("missing database is accepted")
+	}
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var normalizeCountriesCmd = &cobra.Command{
+	Use:     "normalize-countries",
+	Short:   "Print allowed and blocked countries as canonical alpha-2 codes",
+	Example: `geofilter normalize-countries --allow "United States,us,GBR"`,
+	RunE:    normalizeCountries,
+}
+
+func normalizeCountries(cmd *cobra.Command, _ []string) error {
+	allowed, _ := cmd.Flags().GetString(allowFlag)
+	blocked, _ := cmd.Flags().GetString(blockFlag)
+
+	allowedCountries, unknownAllowed := parseCountries(allowed)
+	blockedCountries, unknownBlocked := parseCountries(blocked)
+	unknownCountries := append(unknownAllowed, unknownBlocked...)
+
+	out := cmd.OutOrStdout()
+	if len(strings.TrimSpace(allowed)) > 0 {
+		_, _ = fmt.Fprintf(out, "allow: %s\n", strings.Join(allowedCountries, ","))
+	}
+	if len(strings.TrimSpace(blocked)) > 0 {
+		_, _ = fmt.Fprintf(out, "block: %s\n", strings.Join(blockedCountries, ","))
+	}
+
+	if len(unknownCountries) > 0 {
+		return errors.Errorf("unknown country names: %s", strings.Join(unknownCountries, ","))
+	}
+
+	return nil
+}
+
+func addNormalizeCountriesFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP(allowFlag, "a", "", "List of allowed countries")
+	cmd.Flags().StringP(blockFlag, "b", "", "List of blocked countries")
+}
+
+func init() {
+	addNormalizeCountriesFlags(normalizeCountriesCmd)
+
+	startProxyCmd.AddCommand(normalizeCountriesCmd)
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func runNormalizeCountries(args ...string) (string, error) {
+	cmd := &cobra.Command{Use: "normalize-countries", RunE: normalizeCountries, SilenceUsage: true, SilenceErrors: true}
+	addNormalizeCountriesFlags(cmd)
+
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(args)
+
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestNormalizeCountries(t *testing.T) {
+	out, err := runNormalizeCountries("--allow", "United States,us,GBR", "--block", "Russia")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "allow: GB,US\nblock: RU\n"
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestNormalizeCountriesUnknown(t *testing.T) {
+	out, err := runNormalizeCountries("--allow", "Germany,Atlantis", "--block", "Lemuria")
+	if err == nil || !strings.Contains(err.Error(), "Atlantis,Lemuria") {
+		t.Errorf("unknown countries are not reported: %v", err)
+	}
+	if out != "allow: DE\nblock: \n" {
+		t.Errorf("known countries are not printed:\n%s", out)
+	}
+}
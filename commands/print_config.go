@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// configSummary is the effective configuration printed at startup.
+type configSummary struct {
+	Port             uint     `json:"port"`
+	UnixSocket       string   `json:"unix_socket,omitempty"`
+	Targets          []string `json:"targets,omitempty"`
+	Database         string   `json:"database"`
+	IPv6Database     string   `json:"ipv6_database,omitempty"`
+	ASNDatabase      string   `json:"asn_database,omitempty"`
+	Watch            bool     `json:"watch"`
+	AllowedCountries []string `json:"allowed_countries,omitempty"`
+	BlockedCountries []string `json:"blocked_countries,omitempty"`
+	AllowedNetworks  []string `json:"allowed_networks,omitempty"`
+	BlockedNetworks  []string `json:"blocked_networks,omitempty"`
+	RulesURL         string   `json:"rules_url,omitempty"`
+	Action           string   `json:"action"`
+}
+
+// redact hides passwords of URLs.
+func redact(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+
+	return u.String()
+}
+
+// blockAction returns the type of responses to blocked requests. When several block responses are
+// configured, the first one in the order of the proxy options writes the response.
+func blockAction(cmd *cobra.Command) string {
+	softBlock, _ := cmd.Flags().GetString(softBlockFlag)
+	message, _ := cmd.Flags().GetString(messageFlag)
+	redirect, _ := cmd.Flags().GetString(redirectFlag)
+	honeypot, _ := cmd.Flags().GetString(honeypotFlag)
+	blockBackend, _ := cmd.Flags().GetString(blockBackendFlag)
+	noContent, _ := cmd.Flags().GetBool(noContentFlag)
+	file, _ := cmd.Flags().GetString(fileFlag)
+	file = strings.TrimSpace(file)
+
+	switch {
+	case len(strings.TrimSpace(softBlock)) > 0:
+		return "soft block"
+	case len(strings.TrimSpace(message)) > 0:
+		return "message"
+	case len(strings.TrimSpace(redirect)) > 0:
+		return "redirect"
+	case len(strings.TrimSpace(honeypot)) > 0:
+		return "honeypot"
+	case len(strings.TrimSpace(blockBackend)) > 0:
+		return "block backend"
+	case noContent:
+		return "no content"
+	case strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://"):
+		return "remote page"
+	case len(file) > 0:
+		return "file"
+	default:
+		return "forbidden"
+	}
+}
+
+// newConfigSummary collects the effective configuration from flags, passwords of URLs are redacted.
+func newConfigSummary(cmd *cobra.Command) configSummary {
+	port, _ := cmd.Flags().GetUint(portFlag)
+	unixSocket, _ := cmd.Flags().GetString(unixSocketFlag)
+	target, _ := cmd.Flags().GetString(targetFlag)
+	weightedTargets, _ := cmd.Flags().GetStringToInt(targetsFlag)
+	database, _ := cmd.Flags().GetString(databaseFlag)
+	ipv6Database, _ := cmd.Flags().GetString(ipv6DatabaseFlag)
+	asnDatabase, _ := cmd.Flags().GetString(asnDatabaseFlag)
+	watch, _ := cmd.Flags().GetBool(watchFlag)
+	allowed, _ := cmd.Flags().GetString(allowFlag)
+	blocked, _ := cmd.Flags().GetString(blockFlag)
+	allowEU, _ := cmd.Flags().GetBool(allowEUFlag)
+	blockEU, _ := cmd.Flags().GetBool(blockEUFlag)
+	allowedNetworks, _ := cmd.Flags().GetString(allowNetworksFlag)
+	blockedNetworks, _ := cmd.Flags().GetString(blockNetworksFlag)
+	rulesURL, _ := cmd.Flags().GetString(rulesURLFlag)
+
+	summary := configSummary{
+		Port:            port,
+		UnixSocket:      strings.TrimSpace(unixSocket),
+		Database:        strings.TrimSpace(database),
+		IPv6Database:    strings.TrimSpace(ipv6Database),
+		ASNDatabase:     strings.TrimSpace(asnDatabase),
+		Watch:           watch,
+		AllowedNetworks: splitList(allowedNetworks),
+		BlockedNetworks: splitList(blockedNetworks),
+		RulesURL:        redact(strings.TrimSpace(rulesURL)),
+		Action:          blockAction(cmd),
+	}
+
+	if target = strings.TrimSpace(target); len(target) > 0 {
+		summary.Targets = append(summary.Targets, redact(target))
+	}
+	for weightedTarget, weight := range weightedTargets {
+		summary.Targets = append(summary.Targets, fmt.Sprintf("%s=%d", redact(weightedTarget), weight))
+	}
+	sort.Strings(summary.Targets)
+
+	if allowEU {
+		allowed += "," + euToken
+	}
+	if blockEU {
+		blocked += "," + euToken
+	}
+	if known, _ := parseCountries(allowed); len(known) > 0 {
+		summary.AllowedCountries = known
+	}
+	if known, _ := parseCountries(blocked); len(known) > 0 {
+		summary.BlockedCountries = known
+	}
+
+	return summary
+}
+
+// printConfig writes the effective configuration as JSON.
+func printConfig(cmd *cobra.Command, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newConfigSummary(cmd))
+}
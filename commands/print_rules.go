@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"geofilter/proxy"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const formatFlag = "format"
+
+var printRulesCmd = &cobra.Command{
+	Use:     "print-rules",
+	Short:   "Print normalized filtering rules without starting the server",
+	Example: `geofilter print-rules --allow "EU,GB" --block-networks 10.0.0.0-10.0.0.9 --format json`,
+	RunE:    printRules,
+}
+
+type rules struct {
+	AllowCountries []string `json:"allow_countries,omitempty"`
+	BlockCountries []string `json:"block_countries,omitempty"`
+	AllowNetworks  []string `json:"allow_networks,omitempty"`
+	BlockNetworks  []string `json:"block_networks,omitempty"`
+	BlockASNs      []uint   `json:"block_asns,omitempty"`
+}
+
+func splitList(list string) []string {
+	if len(strings.TrimSpace(list)) == 0 {
+		return nil
+	}
+
+	return strings.Split(list, ",")
+}
+
+func printRules(cmd *cobra.Command, _ []string) error {
+	allowed, _ := cmd.Flags().GetString(allowFlag)
+	blocked, _ := cmd.Flags().GetString(blockFlag)
+	allowedNetworks, _ := cmd.Flags().GetString(allowNetworksFlag)
+	blockedNetworks, _ := cmd.Flags().GetString(blockNetworksFlag)
+	blockedASNs, _ := cmd.Flags().GetUintSlice(blockASNsFlag)
+	strict, _ := cmd.Flags().GetBool(strictFlag)
+	format, _ := cmd.Flags().GetString(formatFlag)
+
+	allowed = strings.TrimSpace(allowed)
+	blocked = strings.TrimSpace(blocked)
+	allowedNetworks = strings.TrimSpace(allowedNetworks)
+	blockedNetworks = strings.TrimSpace(blockedNetworks)
+
+	if err := checkExclusiveRules(allowed, blocked, allowedNetworks, blockedNetworks); err != nil {
+		return err
+	}
+
+	// the rules are validated by the same options the proxy is started with
+	countriesOpt, ignoredCountries, err := getCountriesOpt(allowed, blocked, strict)
+	if err != nil {
+		return err
+	}
+	if len(ignoredCountries) > 0 {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "WARNING: unknown country names are ignored: %v\n", ignoredCountries)
+	}
+	opts := []proxy.StartOption{countriesOpt}
+	if networksOpt := getNetworksOpt(allowedNetworks, blockedNetworks); networksOpt != nil {
+		opts = append(opts, networksOpt)
+	}
+	if len(blockedASNs) > 0 {
+		opts = append(opts, proxy.WithBlockedASNs(blockedASNs))
+	}
+	if _, err := proxy.New(0, "", "", opts...); err != nil {
+		return err
+	}
+
+	var r rules
+	r.AllowCountries, _ = parseCountries(allowed)
+	r.BlockCountries, _ = parseCountries(blocked)
+
+	if r.AllowNetworks, err = proxy.NormalizeNetworks(splitList(allowedNetworks)); err != nil {
+		return err
+	}
+	if r.BlockNetworks, err = proxy.NormalizeNetworks(splitList(blockedNetworks)); err != nil {
+		return err
+	}
+	r.BlockASNs = blockedASNs
+
+	out := cmd.OutOrStdout()
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case "text":
+		printRuleList(cmd, "allow countries", r.AllowCountries)
+		printRuleList(cmd, "block countries", r.BlockCountries)
+		printRuleList(cmd, "allow networks", r.AllowNetworks)
+		printRuleList(cmd, "block networks", r.BlockNetworks)
+		if len(r.BlockASNs) > 0 {
+			_, _ = fmt.Fprintf(out, "block asns: %v\n", r.BlockASNs)
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown format '%s', expected text or json", format)
+	}
+}
+
+func printRuleList(cmd *cobra.Command, name string, list []string) {
+	if len(list) > 0 {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", name, strings.Join(list, ","))
+	}
+}
+
+func addPrintRulesFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP(allowFlag, "a", "", "List of allowed countries")
+	cmd.Flags().StringP(blockFlag, "b", "", "List of blocked countries")
+	cmd.Flags().String(allowNetworksFlag, "", "List of allowed networks (CIDR, start-end range or address)")
+	cmd.Flags().String(blockNetworksFlag, "", "List of blocked networks (CIDR, start-end range or address)")
+	cmd.Flags().UintSlice(blockASNsFlag, nil, "List of blocked autonomous system numbers")
+	cmd.Flags().Bool(strictFlag, false, "Fail when the allowed or blocked lists contain unknown countries")
+	cmd.Flags().String(formatFlag, "text", "Output format: text or json")
+}
+
+func init() {
+	addPrintRulesFlags(printRulesCmd)
+
+	startProxyCmd.AddCommand(printRulesCmd)
+}
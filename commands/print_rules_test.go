@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"geofilter/proxy"
+	"geofilter/proxy/proxytest"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func runPrintRules(args ...string) (string, error) {
+	cmd := &cobra.Command{Use: "print-rules", RunE: printRules, SilenceUsage: true, SilenceErrors: true}
+	addPrintRulesFlags(cmd)
+
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(args)
+
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestPrintRulesJSON(t *testing.T) {
+	out, err := runPrintRules(
+		"--allow", "Germany,France",
+		"--block-networks", "10.0.0.0-10.0.0.9",
+		"--block-asns", "64496,64497",
+		"--format", "json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var printed rules
+	if err := json.Unmarshal([]byte(out), &printed); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+
+	expected := rules{
+		AllowCountries: []string{"DE", "FR"},
+		BlockNetworks:  []string{"10.0.0.0/29", "10.0.0.8/31"},
+		BlockASNs:      []uint{64496, 64497},
+	}
+	if !reflect.DeepEqual(printed, expected) {
+		t.Errorf("expected %+v, got %+v", expected, printed)
+	}
+}
+
+func TestPrintRulesErrors(t *testing.T) {
+	tests := [][]string{
+		{"--allow", "Germany", "--block", "France"},
+		{"--allow-networks", "10.0.0.0/8", "--block-networks", "192.168.0.0/16"},
+		{"--allow", "Germany,Atlantis", "--strict-countries"},
+		{"--allow", "Atlantis"},
+		{"--block-networks", "10.0.0.300"},
+		{"--allow", "Germany", "--format", "yaml"},
+	}
+
+	for _, args := range tests {
+		if _, err := runPrintRules(args...); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestPrintRulesIgnoresUnknownCountries(t *testing.T) {
+	out, err := runPrintRules("--allow", "Germany,Atlantis", "--format", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var printed rules
+	if err := json.Unmarshal([]byte(out), &printed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(printed.AllowCountries, []string{"DE"}) {
+		t.Errorf("expected unknown countries to be ignored, got %v", printed.AllowCountries)
+	}
+}
+
+func TestPrintRulesAsRemoteRules(t *testing.T) {
+	serve := func(args ...string) *httptest.Server {
+		out, err := runPrintRules(append(args, "--format", "json")...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+			_, _ = res.Write([]byte(out))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	countries := proxytest.Countries{"1.1.1.1": "US", "2.2.2.2": "DE", "10.0.0.1": "US"}
+
+	tests := []struct {
+		name    string
+		rules   *httptest.Server
+		allowed map[string]bool
+	}{
+		{"countries and networks", serve("--allow", "US", "--block-networks", "10.0.0.0/8"),
+			map[string]bool{"1.1.1.1": true, "2.2.2.2": false, "10.0.0.1": false}},
+		// rules with ASNs are rejected, the configured rules are kept
+		{"ASNs", serve("--allow", "US", "--block-asns", "64496"),
+			map[string]bool{"1.1.1.1": true, "2.2.2.2": true, "10.0.0.1": false}},
+	}
+
+	for _, test := range tests {
+		for addr, allowed := range test.allowed {
+			decision, _, err := proxytest.Decide(countries, proxytest.NewRequest(addr),
+				proxy.WithBlockedNetworks([]string{"10.0.0.0/8"}),
+				proxy.WithRemoteRules(test.rules.URL, time.Hour),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if decision.Allowed != allowed {
+				t.Errorf("%s: expected %s to be allowed %t, got %t", test.name, addr, allowed, decision.Allowed)
+			}
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package commands
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"geofilter/proxy"
 	"github.com/biter777/countries"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -19,6 +27,88 @@ const (
 	watchFlag    = "watch"
 	allowFlag    = "allow"
 	blockFlag    = "block"
+
+	databaseEnv       = "GEOFILTER_DATABASE"
+	defaultDatabase   = "GeoLite2-Country.mmdb"
+	lookupTimeoutFlag = "lookup-timeout"
+	allowNetworksFlag = "allow-networks"
+	blockNetworksFlag = "block-networks"
+	strictFlag        = "strict-countries"
+	anonymizeFlag     = "anonymize-ip"
+	allowEUFlag       = "allow-eu"
+	blockEUFlag       = "block-eu"
+	countrySourceFlag = "country-source"
+	blockAnonFlag     = "block-anonymous"
+	blockHostingFlag  = "block-hosting"
+	retriesFlag       = "backend-retries"
+	logFileFlag       = "log-file"
+	logMaxSizeFlag    = "log-max-size"
+	logMaxBackupsFlag = "log-max-backups"
+	logMaxAgeFlag     = "log-max-age"
+	accessLogFlag     = "access-log"
+	selfTestFlag      = "selftest-ips"
+	rewriteFlag       = "rewrite-redirects"
+	tarpitFlag        = "tarpit"
+	tarpitMaxFlag     = "tarpit-max-connections"
+	preservePathFlag  = "redirect-preserve-path"
+	redirectCodeFlag  = "redirect-code"
+	versionPathFlag   = "version-endpoint"
+	adminFlag         = "admin-listen"
+	ipv6DatabaseFlag  = "ipv6-database"
+	asnDatabaseFlag   = "asn-database"
+	blockASNsFlag     = "block-asns"
+	geoJSONFlag       = "geo-json-header"
+	breakerFlag       = "circuit-breaker"
+	fileRefreshFlag   = "file-refresh"
+	prefixCacheFlag   = "prefix-cache"
+	serverTimingFlag  = "server-timing"
+	unixSocketFlag    = "unix-socket"
+	scheduleFlag      = "country-schedule"
+	lazyTargetFlag    = "lazy-target"
+	strictTargetFlag  = "strict-target"
+	noContentFlag     = "no-content"
+	testConfigFlag    = "test-config"
+	headerNameFlag    = "header-name"
+	honeypotFlag      = "honeypot"
+	cacheWarmupFlag   = "cache-warmup"
+	softBlockFlag     = "soft-block"
+	quietFlag         = "quiet"
+	targetsFlag       = "weighted-targets"
+	printConfigFlag   = "print-config"
+	noGeoHeaderFlag   = "no-geo-header"
+	blockPTRFlag      = "block-ptr"
+	cacheTTLFlag      = "cache-ttl"
+	corsOriginsFlag   = "blocked-cors-origins"
+	listenFlag        = "listen"
+	tlsListenFlag     = "tls-listen"
+	tlsCertFlag       = "tls-cert"
+	tlsKeyFlag        = "tls-key"
+	breakerResetFlag  = "circuit-breaker-reset"
+	networksFirstFlag = "networks-over-countries"
+	lookupAPIFlag     = "lookup-api"
+	lookupRateFlag    = "lookup-rate-limit"
+	xffStrategyFlag   = "xff-strategy"
+	trustedProxyFlag  = "trusted-proxies"
+	debugStreamFlag   = "debug-stream"
+	blockEmptyUAFlag  = "block-empty-user-agent"
+	allowMethodsFlag  = "allow-methods"
+	badRequestFlag    = "bad-request-status"
+	badRequestMsgFlag = "bad-request-body"
+	rulesURLFlag      = "rules-url"
+	rulesRefreshFlag  = "rules-refresh"
+	maxIdleConnsFlag  = "max-idle-conns"
+	maxConnsFlag      = "max-conns-per-host"
+	idleTimeoutFlag   = "idle-timeout"
+	overloadFlag      = "overload-cooldown"
+	viaHeaderFlag     = "via-header"
+	blockHeaderFlag   = "block-header"
+	waitForDbFlag     = "wait-for-database"
+	statsdFlag        = "statsd"
+	countryRateFlag   = "country-rate-limit"
+	mapCountryFlag    = "map-country"
+	blockBackendFlag  = "block-backend"
+	tlsClientCAFlag   = "tls-client-ca"
+	maxHeaderFlag     = "max-header-bytes"
 )
 
 var startProxyCmd = &cobra.Command{
@@ -26,64 +116,245 @@ var startProxyCmd = &cobra.Command{
 	Short:   "Geo IP filter",
 	Long:    "",
 	Example: "geofilter --database=GeoLite2-Country.mmdb --port 3000 --allow US --target http://localhost:4001",
-	Version: "", //BuildVersion,
 	RunE:    startProxy,
 }
 
-func getCountriesOpt(allowed string, blocked string) (proxy.StartOption, error) {
-	unknownCountries := make([]string, 0)
-	allowedCountries := make([]string, 0)
-	blockedCountries := make([]string, 0)
+// euToken is a pseudo country expanded to member states of the European Union.
+const euToken = "EU"
+
+// parseCountries converts a comma separated list of country names and codes to a sorted list
+// of unique alpha-2 codes, the EU token is expanded to the member states of the European Union.
+// Entries which are not recognized are returned separately.
+func parseCountries(list string) (known []string, unknown []string) {
+	known = make([]string, 0)
+	unknown = make([]string, 0)
+	seen := make(map[string]bool)
 
-	for _, c := range strings.Split(allowed, ",") {
+	for _, c := range strings.Split(list, ",") {
+		c = strings.TrimSpace(c)
 		if len(c) == 0 {
 			continue
 		}
-		country := countries.ByName(c)
-		if country == countries.Unknown {
-			unknownCountries = append(unknownCountries, c)
-		} else {
-			allowedCountries = append(allowedCountries, country.Alpha2())
+
+		if strings.EqualFold(c, euToken) {
+			for _, code := range proxy.EUMembers {
+				if !seen[code] {
+					seen[code] = true
+					known = append(known, code)
+				}
+			}
+			continue
 		}
-	}
 
-	for _, c := range strings.Split(blocked, ",") {
-		if len(c) == 0 {
+		// pseudo countries like "None" have no alpha-2 code
+		code := countries.ByName(c).Alpha2()
+		if len(code) != 2 {
+			unknown = append(unknown, c)
 			continue
 		}
-		country := countries.ByName(c)
-		if country == countries.Unknown {
-			unknownCountries = append(unknownCountries, c)
-		} else {
-			blockedCountries = append(blockedCountries, country.Alpha2())
+
+		if !seen[code] {
+			seen[code] = true
+			known = append(known, code)
 		}
 	}
 
+	sort.Strings(known)
+
+	return known, unknown
+}
+
+// getCountriesOpt returns an option with country rules. Unknown country names fail in the strict mode,
+// otherwise they are reported by the returned names, which are passed to proxy.WithIgnoredCountries.
+func getCountriesOpt(allowed string, blocked string, strict bool) (proxy.StartOption, []string, error) {
+	allowedCountries, unknownAllowed := parseCountries(allowed)
+	blockedCountries, unknownBlocked := parseCountries(blocked)
+	unknownCountries := append(unknownAllowed, unknownBlocked...)
+
+	if len(unknownCountries) > 0 && strict {
+		return nil, nil, errors.Errorf("unknown country names: %v", unknownCountries)
+	}
+
 	if len(allowed) > 0 {
 		if len(allowedCountries) == 0 {
-			if len(unknownCountries) == 0 {
-				return nil, errors.Errorf("unknown country names: %v\n", unknownCountries)
+			if len(unknownCountries) > 0 {
+				return nil, nil, errors.Errorf("unknown country names: %v\n", unknownCountries)
 			}
 
-			return nil, errors.Errorf("empty countries list")
+			return nil, nil, errors.Errorf("empty countries list")
 		}
 
-		return proxy.WithAllowedCountries(allowedCountries), nil
+		return proxy.WithAllowedCountries(allowedCountries), unknownCountries, nil
 	}
 
 	if len(blocked) > 0 {
 		if len(blockedCountries) == 0 {
-			if len(unknownCountries) == 0 {
-				return nil, errors.Errorf("unknown country names: %v\n", unknownCountries)
+			if len(unknownCountries) > 0 {
+				return nil, nil, errors.Errorf("unknown country names: %v\n", unknownCountries)
 			}
 
-			return nil, errors.Errorf("empty countries list")
+			return nil, nil, errors.Errorf("empty countries list")
+		}
+
+		return proxy.WithBlockedCountries(blockedCountries), unknownCountries, nil
+	}
+
+	return proxy.WithNoFilter(), nil, nil
+}
+
+// checkExclusiveRules checks that allow and block lists are not combined.
+func checkExclusiveRules(allowed string, blocked string, allowedNetworks string, blockedNetworks string) error {
+	if len(allowed) > 0 && len(blocked) > 0 {
+		return errors.Errorf("--%s and --%s options are mutually exclusive", allowFlag, blockFlag)
+	}
+
+	if len(allowedNetworks) > 0 && len(blockedNetworks) > 0 {
+		return errors.Errorf("--%s and --%s options are mutually exclusive", allowNetworksFlag, blockNetworksFlag)
+	}
+
+	return nil
+}
+
+func getNetworksOpt(allowed string, blocked string) proxy.StartOption {
+	if len(allowed) > 0 {
+		return proxy.WithAllowedNetworks(strings.Split(allowed, ","))
+	}
+
+	if len(blocked) > 0 {
+		return proxy.WithBlockedNetworks(strings.Split(blocked, ","))
+	}
+
+	return nil
+}
+
+func getXFFStrategyOpt(strategy string, trustedProxies []string) (proxy.StartOption, error) {
+	switch strategy {
+	case "":
+		return nil, nil
+	case "leftmost":
+		return proxy.WithXFFStrategy(proxy.XFFLeftmost()), nil
+	case "rightmost-trusted":
+		if len(trustedProxies) == 0 {
+			return nil, errors.Errorf("--%s=%s requires --%s", xffStrategyFlag, strategy, trustedProxyFlag)
+		}
+		return proxy.WithXFFStrategy(proxy.XFFRightmostTrusted(trustedProxies)), nil
+	}
+
+	index, err := strconv.Atoi(strategy)
+	if err != nil {
+		return nil, errors.Errorf("invalid X-Forwarded-For strategy '%s', expected leftmost, rightmost-trusted or an index", strategy)
+	}
+
+	return proxy.WithXFFStrategy(proxy.XFFIndex(index)), nil
+}
+
+func getCountrySourceOpt(source string) (proxy.StartOption, error) {
+	switch source {
+	case "", "country":
+		return proxy.WithCountrySource(proxy.SourceCountry), nil
+	case "registered":
+		return proxy.WithCountrySource(proxy.SourceRegisteredCountry), nil
+	case "represented":
+		return proxy.WithCountrySource(proxy.SourceRepresentedCountry), nil
+	default:
+		return nil, errors.Errorf("unknown country source '%s'", source)
+	}
+}
+
+func getSelfTestOpt(selfTest string) (proxy.StartOption, error) {
+	expected := make(map[string]string)
+	for _, entry := range strings.Split(selfTest, ",") {
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid self-test entry '%s', expected ip=country", entry)
+		}
+
+		country := countries.ByName(strings.TrimSpace(parts[1]))
+		if country == countries.Unknown {
+			return nil, errors.Errorf("unknown country name: %s", parts[1])
+		}
+
+		expected[strings.TrimSpace(parts[0])] = country.Alpha2()
+	}
+
+	return proxy.WithStartupSelfTest(expected), nil
+}
+
+// getScheduleOpt parses a schedule in the COUNTRY=HH:MM-HH:MM,HH:MM-HH:MM@TIMEZONE format.
+// The timezone is optional and defaults to UTC.
+func getScheduleOpt(schedule string) (proxy.StartOption, error) {
+	parts := strings.SplitN(schedule, "=", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid schedule '%s', expected COUNTRY=HH:MM-HH:MM[@TIMEZONE]", schedule)
+	}
+
+	country := countries.ByName(strings.TrimSpace(parts[0]))
+	if len(country.Alpha2()) != 2 {
+		return nil, errors.Errorf("unknown country name: %s", parts[0])
+	}
+
+	tz := "UTC"
+	windowList := parts[1]
+	if i := strings.LastIndex(windowList, "@"); i >= 0 {
+		tz = strings.TrimSpace(windowList[i+1:])
+		windowList = windowList[:i]
+	}
+
+	var windows []proxy.TimeWindow
+	for _, w := range strings.Split(windowList, ",") {
+		window, err := proxy.ParseTimeWindow(w)
+		if err != nil {
+			return nil, err
 		}
+		windows = append(windows, window)
+	}
+
+	return proxy.WithCountrySchedule(country.Alpha2(), windows, tz), nil
+}
+
+// getClientCertAuthOpt returns an option to require client certificates signed by CAs loaded from a PEM file.
+func getClientCertAuthOpt(caFile string) (proxy.StartOption, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read client CA file")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no certificates found in client CA file '%s'", caFile)
+	}
+
+	return proxy.WithClientCertAuth(pool), nil
+}
+
+// getTLSListenerOpt returns an option to serve TLS on the address with a certificate loaded from files.
+func getTLSListenerOpt(addr, certFile, keyFile string) (proxy.StartOption, error) {
+	if len(certFile) == 0 || len(keyFile) == 0 {
+		return nil, errors.Errorf("--%s requires --%s and --%s options", tlsListenFlag, tlsCertFlag, tlsKeyFlag)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load TLS certificate")
+	}
 
-		return proxy.WithBlockedCountries(blockedCountries), nil
+	return proxy.WithAdditionalListener(addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}), nil
+}
+
+// getTransport returns a transport to the target configured by the connection pool flags,
+// it returns nil when none of them is set.
+func getTransport(cmd *cobra.Command) *http.Transport {
+	maxIdleConns, _ := cmd.Flags().GetInt(maxIdleConnsFlag)
+	maxConns, _ := cmd.Flags().GetInt(maxConnsFlag)
+	idleTimeout, _ := cmd.Flags().GetDuration(idleTimeoutFlag)
+
+	if maxIdleConns <= 0 && maxConns <= 0 && idleTimeout <= 0 {
+		return nil
 	}
 
-	return proxy.WithNoFilter(), nil
+	return proxy.NewTransport(maxIdleConns, maxConns, idleTimeout)
 }
 
 func startProxy(cmd *cobra.Command, _ []string) error {
@@ -96,19 +367,115 @@ func startProxy(cmd *cobra.Command, _ []string) error {
 	file, _ := cmd.Flags().GetString(fileFlag)
 	allowed, _ := cmd.Flags().GetString(allowFlag)
 	blocked, _ := cmd.Flags().GetString(blockFlag)
+	lookupTimeout, _ := cmd.Flags().GetDuration(lookupTimeoutFlag)
+	allowedNetworks, _ := cmd.Flags().GetString(allowNetworksFlag)
+	blockedNetworks, _ := cmd.Flags().GetString(blockNetworksFlag)
+	strict, _ := cmd.Flags().GetBool(strictFlag)
+	anonymize, _ := cmd.Flags().GetBool(anonymizeFlag)
+	allowEU, _ := cmd.Flags().GetBool(allowEUFlag)
+	blockEU, _ := cmd.Flags().GetBool(blockEUFlag)
+	countrySource, _ := cmd.Flags().GetString(countrySourceFlag)
+	blockAnonymous, _ := cmd.Flags().GetBool(blockAnonFlag)
+	blockHosting, _ := cmd.Flags().GetBool(blockHostingFlag)
+	retries, _ := cmd.Flags().GetInt(retriesFlag)
+	logFile, _ := cmd.Flags().GetString(logFileFlag)
+	logMaxSize, _ := cmd.Flags().GetInt(logMaxSizeFlag)
+	logMaxBackups, _ := cmd.Flags().GetInt(logMaxBackupsFlag)
+	logMaxAge, _ := cmd.Flags().GetInt(logMaxAgeFlag)
+	accessLog, _ := cmd.Flags().GetString(accessLogFlag)
+	selfTest, _ := cmd.Flags().GetString(selfTestFlag)
+	rewriteRedirects, _ := cmd.Flags().GetBool(rewriteFlag)
+	tarpitDelay, _ := cmd.Flags().GetDuration(tarpitFlag)
+	tarpitMax, _ := cmd.Flags().GetInt(tarpitMaxFlag)
+	preservePath, _ := cmd.Flags().GetBool(preservePathFlag)
+	redirectCode, _ := cmd.Flags().GetInt(redirectCodeFlag)
+	versionPath, _ := cmd.Flags().GetString(versionPathFlag)
+	adminAddr, _ := cmd.Flags().GetString(adminFlag)
+	ipv6Database, _ := cmd.Flags().GetString(ipv6DatabaseFlag)
+	asnDatabase, _ := cmd.Flags().GetString(asnDatabaseFlag)
+	blockedASNs, _ := cmd.Flags().GetUintSlice(blockASNsFlag)
+	geoJSONHeader, _ := cmd.Flags().GetString(geoJSONFlag)
+	prefixCache, _ := cmd.Flags().GetBool(prefixCacheFlag)
+	serverTiming, _ := cmd.Flags().GetBool(serverTimingFlag)
+	unixSocket, _ := cmd.Flags().GetString(unixSocketFlag)
+	schedules, _ := cmd.Flags().GetStringArray(scheduleFlag)
+	lazyTarget, _ := cmd.Flags().GetBool(lazyTargetFlag)
+	strictTarget, _ := cmd.Flags().GetBool(strictTargetFlag)
+	noContent, _ := cmd.Flags().GetBool(noContentFlag)
+	testConfig, _ := cmd.Flags().GetBool(testConfigFlag)
+	headerNames, _ := cmd.Flags().GetStringSlice(headerNameFlag)
+	honeypot, _ := cmd.Flags().GetString(honeypotFlag)
+	cacheWarmup, _ := cmd.Flags().GetString(cacheWarmupFlag)
+	softBlock, _ := cmd.Flags().GetString(softBlockFlag)
+	quiet, _ := cmd.Flags().GetBool(quietFlag)
+	weightedTargets, _ := cmd.Flags().GetStringToInt(targetsFlag)
+	printCfg, _ := cmd.Flags().GetBool(printConfigFlag)
+	noGeoHeader, _ := cmd.Flags().GetBool(noGeoHeaderFlag)
+	blockedPTRs, _ := cmd.Flags().GetStringSlice(blockPTRFlag)
+	cacheTTL, _ := cmd.Flags().GetDuration(cacheTTLFlag)
+	corsOrigins, _ := cmd.Flags().GetStringSlice(corsOriginsFlag)
+	listenAddrs, _ := cmd.Flags().GetStringSlice(listenFlag)
+	tlsListen, _ := cmd.Flags().GetString(tlsListenFlag)
+	tlsCert, _ := cmd.Flags().GetString(tlsCertFlag)
+	tlsKey, _ := cmd.Flags().GetString(tlsKeyFlag)
+	tlsClientCA, _ := cmd.Flags().GetString(tlsClientCAFlag)
+	fileRefresh, _ := cmd.Flags().GetDuration(fileRefreshFlag)
+	breakerThreshold, _ := cmd.Flags().GetInt(breakerFlag)
+	breakerReset, _ := cmd.Flags().GetDuration(breakerResetFlag)
+	networksFirst, _ := cmd.Flags().GetBool(networksFirstFlag)
+	lookupAPIPath, _ := cmd.Flags().GetString(lookupAPIFlag)
+	lookupRate, _ := cmd.Flags().GetInt(lookupRateFlag)
+	xffStrategy, _ := cmd.Flags().GetString(xffStrategyFlag)
+	trustedProxies, _ := cmd.Flags().GetStringSlice(trustedProxyFlag)
+	debugStream, _ := cmd.Flags().GetString(debugStreamFlag)
+	blockEmptyUA, _ := cmd.Flags().GetBool(blockEmptyUAFlag)
+	allowedMethods, _ := cmd.Flags().GetStringSlice(allowMethodsFlag)
+	badRequestStatus, _ := cmd.Flags().GetInt(badRequestFlag)
+	badRequestBody, _ := cmd.Flags().GetString(badRequestMsgFlag)
+	rulesURL, _ := cmd.Flags().GetString(rulesURLFlag)
+	rulesRefresh, _ := cmd.Flags().GetDuration(rulesRefreshFlag)
+	overloadCooldown, _ := cmd.Flags().GetDuration(overloadFlag)
+	viaHeader, _ := cmd.Flags().GetBool(viaHeaderFlag)
+	blockedHeaders, _ := cmd.Flags().GetStringArray(blockHeaderFlag)
+	waitForDb, _ := cmd.Flags().GetDuration(waitForDbFlag)
+	maxHeaderBytes, _ := cmd.Flags().GetInt(maxHeaderFlag)
+	statsdAddr, _ := cmd.Flags().GetString(statsdFlag)
+	countryRates, _ := cmd.Flags().GetStringToInt(countryRateFlag)
+	countryMap, _ := cmd.Flags().GetStringToString(mapCountryFlag)
+	blockBackend, _ := cmd.Flags().GetString(blockBackendFlag)
 
 	allowed = strings.TrimSpace(allowed)
 	blocked = strings.TrimSpace(blocked)
+	allowedNetworks = strings.TrimSpace(allowedNetworks)
+	blockedNetworks = strings.TrimSpace(blockedNetworks)
 
-	if len(allowed) > 0 && len(blocked) > 0 {
-		return errors.Errorf("--%s and --%s options are mutually exclusive", allowFlag, blockFlag)
+	lookupAPIPath = strings.TrimSpace(lookupAPIPath)
+
+	if len(strings.TrimSpace(target)) == 0 && len(weightedTargets) == 0 && len(lookupAPIPath) == 0 {
+		return errors.Errorf("either --%s, --%s or --%s option must be specified", targetFlag, targetsFlag, lookupAPIFlag)
+	}
+
+	if err := checkExclusiveRules(allowed, blocked, allowedNetworks, blockedNetworks); err != nil {
+		return err
+	}
+
+	if allowEU && blockEU {
+		return errors.Errorf("--%s and --%s options are mutually exclusive", allowEUFlag, blockEUFlag)
+	}
+
+	if allowEU && len(blocked) > 0 {
+		return errors.Errorf("--%s option can not be combined with --%s", allowEUFlag, blockFlag)
+	}
+
+	if blockEU && (len(allowed) > 0 || len(blocked) > 0) {
+		return errors.Errorf("--%s option can not be combined with country lists", blockEUFlag)
 	}
 
 	if len(message) > 0 && len(redirect) > 0 {
 		return errors.Errorf("--%s and --%s options are mutually exclusive", redirectFlag, messageFlag)
 	}
 
-	countriesOpt, err := getCountriesOpt(allowed, blocked)
+	countriesOpt, ignoredCountries, err := getCountriesOpt(allowed, blocked, strict)
 	if err != nil {
 		return err
 	}
@@ -116,6 +483,115 @@ func startProxy(cmd *cobra.Command, _ []string) error {
 	var opts []proxy.StartOption
 
 	opts = append(opts, countriesOpt)
+	if len(ignoredCountries) > 0 {
+		opts = append(opts, proxy.WithIgnoredCountries(ignoredCountries))
+	}
+
+	opts = append(opts, proxy.WithVersion(proxy.VersionInfo{
+		Version: BuildVersion,
+		Commit:  BuildCommit,
+		Date:    BuildDate,
+	}))
+
+	adminAddr = strings.TrimSpace(adminAddr)
+	if len(adminAddr) > 0 {
+		opts = append(opts, proxy.WithAdminListener(adminAddr))
+	}
+
+	if len(trustedProxies) > 0 {
+		opts = append(opts, proxy.WithTrustedProxies(trustedProxies))
+	}
+
+	if xffOpt, err := getXFFStrategyOpt(strings.TrimSpace(xffStrategy), trustedProxies); err != nil {
+		return err
+	} else if xffOpt != nil {
+		opts = append(opts, xffOpt)
+	}
+
+	if len(lookupAPIPath) > 0 {
+		opts = append(opts, proxy.WithLookupAPI(lookupAPIPath))
+		if lookupRate > 0 {
+			opts = append(opts, proxy.WithLookupRateLimit(lookupRate, time.Second))
+		}
+	}
+
+	statsdAddr = strings.TrimSpace(statsdAddr)
+	if len(statsdAddr) > 0 {
+		opts = append(opts, proxy.WithStatsD(statsdAddr))
+	}
+
+	debugStream = strings.TrimSpace(debugStream)
+	if len(debugStream) > 0 {
+		if len(adminAddr) == 0 {
+			return errors.Errorf("--%s requires --%s", debugStreamFlag, adminFlag)
+		}
+		opts = append(opts, proxy.WithDecisionStream(debugStream))
+	}
+
+	versionPath = strings.TrimSpace(versionPath)
+	if len(versionPath) > 0 {
+		opts = append(opts, proxy.WithVersionEndpoint(versionPath))
+	}
+
+	countrySourceOpt, err := getCountrySourceOpt(strings.TrimSpace(countrySource))
+	if err != nil {
+		return err
+	}
+	opts = append(opts, countrySourceOpt)
+
+	if blockAnonymous {
+		opts = append(opts, proxy.WithBlockAnonymous())
+	}
+
+	if blockHosting {
+		opts = append(opts, proxy.WithBlockHosting())
+	}
+
+	if allowEU {
+		opts = append(opts, proxy.WithAllowEUOnly())
+	}
+
+	if blockEU {
+		opts = append(opts, proxy.WithBlockEU())
+	}
+
+	if networksOpt := getNetworksOpt(allowedNetworks, blockedNetworks); networksOpt != nil {
+		opts = append(opts, networksOpt)
+	}
+
+	rulesURL = strings.TrimSpace(rulesURL)
+	if len(rulesURL) > 0 {
+		opts = append(opts, proxy.WithRemoteRules(rulesURL, rulesRefresh))
+	}
+
+	if len(countryMap) > 0 {
+		mapping := make(map[string]string, len(countryMap))
+		for from, to := range countryMap {
+			mapping[strings.ToUpper(strings.TrimSpace(from))] = strings.ToUpper(strings.TrimSpace(to))
+		}
+		opts = append(opts, proxy.WithCountryMapper(func(isoCode string) string {
+			if mapped, ok := mapping[isoCode]; ok {
+				return mapped
+			}
+			return isoCode
+		}))
+	}
+
+	if len(countryRates) > 0 {
+		limits := make(map[string]proxy.RateLimit, len(countryRates))
+		for country, rps := range countryRates {
+			limits[country] = proxy.RateLimit{Requests: rps, Window: time.Second}
+		}
+		opts = append(opts, proxy.WithCountryRateLimits(limits))
+	}
+
+	if networksFirst {
+		if len(allowedNetworks) == 0 {
+			return errors.Errorf("--%s requires --%s", networksFirstFlag, allowNetworksFlag)
+		}
+
+		opts = append(opts, proxy.WithCIDRPrecedence("country"))
+	}
 
 	message = strings.TrimSpace(message)
 	if len(message) > 0 {
@@ -125,25 +601,281 @@ func startProxy(cmd *cobra.Command, _ []string) error {
 	redirect = strings.TrimSpace(redirect)
 	if len(redirect) > 0 {
 		opts = append(opts, proxy.WithRedirect(redirect))
+		opts = append(opts, proxy.WithRedirectStatus(redirectCode))
+		if preservePath {
+			opts = append(opts, proxy.WithRedirectPreservePath())
+		}
+	}
+
+	if viaHeader {
+		opts = append(opts, proxy.WithViaHeader())
+	}
+
+	if overloadCooldown > 0 {
+		opts = append(opts, proxy.WithOverloadDetection(overloadCooldown))
+	}
+
+	if transport := getTransport(cmd); transport != nil {
+		opts = append(opts, proxy.WithTransport(transport))
+	}
+
+	if len(weightedTargets) > 0 {
+		opts = append(opts, proxy.WithWeightedTargets(weightedTargets))
+	}
+
+	if quiet {
+		opts = append(opts, proxy.WithQuiet())
+	}
+
+	softBlock = strings.TrimSpace(softBlock)
+	if len(softBlock) > 0 {
+		opts = append(opts, proxy.WithSoftBlock(softBlock))
+	}
+
+	honeypot = strings.TrimSpace(honeypot)
+	if len(honeypot) > 0 {
+		opts = append(opts, proxy.WithHoneypotTarget(honeypot))
+	}
+
+	blockBackend = strings.TrimSpace(blockBackend)
+	if len(blockBackend) > 0 {
+		if len(honeypot) > 0 {
+			return errors.Errorf("--%s and --%s options are mutually exclusive", honeypotFlag, blockBackendFlag)
+		}
+		opts = append(opts, proxy.WithBlockBackend(blockBackend))
+	}
+
+	if len(corsOrigins) > 0 {
+		opts = append(opts, proxy.WithBlockedPreflightCORS(corsOrigins))
+	}
+
+	if noContent {
+		opts = append(opts, proxy.WithNoContentBlock())
 	}
 
 	file = strings.TrimSpace(file)
-	if len(file) > 0 {
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+		opts = append(opts, proxy.WithRemoteBlockPage(file))
+		if fileRefresh > 0 {
+			opts = append(opts, proxy.WithBlockPageRefresh(fileRefresh))
+		}
+	} else if len(file) > 0 {
 		opts = append(opts, proxy.WithFile(file))
 	}
 
+	selfTest = strings.TrimSpace(selfTest)
+	if len(selfTest) > 0 {
+		selfTestOpt, err := getSelfTestOpt(selfTest)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, selfTestOpt)
+	}
+
+	if tarpitDelay > 0 {
+		opts = append(opts, proxy.WithTarpit(tarpitDelay, tarpitMax))
+	}
+
+	ipv6Database = strings.TrimSpace(ipv6Database)
+	if len(ipv6Database) > 0 {
+		opts = append(opts, proxy.WithIPv6Database(ipv6Database))
+	}
+
+	if maxHeaderBytes > 0 {
+		opts = append(opts, proxy.WithMaxHeaderBytes(maxHeaderBytes))
+	}
+
+	if waitForDb > 0 {
+		opts = append(opts, proxy.WithWaitForDatabase(waitForDb))
+	}
+
+	asnDatabase = strings.TrimSpace(asnDatabase)
+	if len(asnDatabase) > 0 {
+		opts = append(opts, proxy.WithASNDatabase(asnDatabase))
+	}
+
+	if cmd.Flags().Changed(badRequestFlag) || len(badRequestBody) > 0 {
+		opts = append(opts, proxy.WithBadRequestResponse(badRequestStatus, badRequestBody))
+	}
+
+	if len(allowedMethods) > 0 {
+		opts = append(opts, proxy.WithAllowedMethods(allowedMethods))
+	}
+
+	for _, header := range blockedHeaders {
+		parts := strings.SplitN(header, "=", 2)
+		pattern := ""
+		if len(parts) == 2 {
+			pattern = parts[1]
+		}
+		opts = append(opts, proxy.WithBlockIfHeader(parts[0], pattern))
+	}
+
+	if blockEmptyUA {
+		opts = append(opts, proxy.WithBlockEmptyUserAgent())
+	}
+
+	if len(blockedPTRs) > 0 {
+		opts = append(opts, proxy.WithBlockedPTRPatterns(blockedPTRs))
+	}
+
+	if len(blockedASNs) > 0 {
+		opts = append(opts, proxy.WithBlockedASNs(blockedASNs))
+	}
+
 	if watch {
 		opts = append(opts, proxy.WithAutoReload())
 	}
 
+	logFile = strings.TrimSpace(logFile)
+	if len(logFile) > 0 {
+		opts = append(opts, proxy.WithLogFile(logFile, logMaxSize, logMaxBackups, logMaxAge))
+	}
+
+	accessLog = strings.TrimSpace(accessLog)
+	if accessLog == "-" {
+		opts = append(opts, proxy.WithCLFAccessLog(os.Stdout))
+	} else if len(accessLog) > 0 {
+		accessLogFile, err := os.OpenFile(accessLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open access log '%s'", accessLog)
+		}
+		defer func() {
+			_ = accessLogFile.Close()
+		}()
+		opts = append(opts, proxy.WithCLFAccessLog(accessLogFile))
+	}
+
+	if anonymize {
+		opts = append(opts, proxy.WithIPAnonymization())
+	}
+
+	if noGeoHeader {
+		opts = append(opts, proxy.WithInjectGeoHeader(false))
+	}
+
+	if len(headerNames) > 0 {
+		opts = append(opts, proxy.WithHeaderName(headerNames...))
+	}
+
+	geoJSONHeader = strings.TrimSpace(geoJSONHeader)
+	if len(geoJSONHeader) > 0 {
+		opts = append(opts, proxy.WithGeoJSONHeader(geoJSONHeader))
+	}
+
+	if rewriteRedirects {
+		opts = append(opts, proxy.WithRewriteRedirects())
+	}
+
+	if retries > 0 {
+		opts = append(opts, proxy.WithBackendRetries(retries))
+	}
+
+	if breakerThreshold > 0 {
+		opts = append(opts, proxy.WithCircuitBreaker(breakerThreshold, breakerReset))
+	}
+
+	if lookupTimeout > 0 {
+		opts = append(opts, proxy.WithLookupTimeout(lookupTimeout))
+	}
+
+	if prefixCache {
+		opts = append(opts, proxy.WithPrefixCache())
+	}
+
+	if cacheTTL > 0 {
+		opts = append(opts, proxy.WithLookupCacheTTL(cacheTTL))
+	}
+
+	cacheWarmup = strings.TrimSpace(cacheWarmup)
+	if len(cacheWarmup) > 0 {
+		opts = append(opts, proxy.WithCacheWarmup(cacheWarmup))
+	}
+
+	if serverTiming {
+		opts = append(opts, proxy.WithServerTiming())
+	}
+
+	if lazyTarget {
+		opts = append(opts, proxy.WithLazyTarget())
+	}
+
+	if strictTarget {
+		opts = append(opts, proxy.WithStrictTarget())
+	}
+
+	for _, schedule := range schedules {
+		scheduleOpt, err := getScheduleOpt(schedule)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, scheduleOpt)
+	}
+
+	for _, addr := range listenAddrs {
+		opts = append(opts, proxy.WithAdditionalListener(strings.TrimSpace(addr), nil))
+	}
+
+	tlsListen = strings.TrimSpace(tlsListen)
+	if len(tlsListen) > 0 {
+		tlsOpt, err := getTLSListenerOpt(tlsListen, strings.TrimSpace(tlsCert), strings.TrimSpace(tlsKey))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, tlsOpt)
+	}
+
+	tlsClientCA = strings.TrimSpace(tlsClientCA)
+	if len(tlsClientCA) > 0 {
+		if len(tlsListen) == 0 {
+			return errors.Errorf("--%s requires --%s option", tlsClientCAFlag, tlsListenFlag)
+		}
+
+		caOpt, err := getClientCertAuthOpt(tlsClientCA)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, caOpt)
+	}
+
+	unixSocket = strings.TrimSpace(unixSocket)
+	if len(unixSocket) > 0 {
+		opts = append(opts, proxy.WithUnixSocket(unixSocket))
+	}
+
 	geoProxy, err := proxy.New(port, database, target, opts...)
 	if err != nil {
 		return err
 	}
 
+	if printCfg {
+		if err := printConfig(cmd, cmd.OutOrStdout()); err != nil {
+			return err
+		}
+	}
+
+	if testConfig {
+		// opening a proxy loads databases and checks the target without serving requests
+		if err := geoProxy.Open(); err != nil {
+			return err
+		}
+		_ = geoProxy.Close()
+
+		cmd.Println("configuration is valid")
+		return nil
+	}
+
 	return geoProxy.Start()
 }
 
+func getDefaultDatabase() string {
+	if database := strings.TrimSpace(os.Getenv(databaseEnv)); len(database) > 0 {
+		return database
+	}
+
+	return defaultDatabase
+}
+
 // RunApp starts a proxy
 func RunApp() {
 	if err := startProxyCmd.Execute(); err != nil {
@@ -151,17 +883,102 @@ func RunApp() {
 	}
 }
 
+func addStartProxyFlags(cmd *cobra.Command) {
+	cmd.Flags().UintP(portFlag, "p", 80, "port")
+	cmd.Flags().Bool(printConfigFlag, false, "Print the effective configuration as JSON at startup")
+	cmd.Flags().Bool(testConfigFlag, false, "Validate the configuration, load the databases and exit")
+	cmd.Flags().StringSlice(listenFlag, nil, "Additional addresses to serve requests on, e.g. :8080")
+	cmd.Flags().String(tlsListenFlag, "", "Additional address to serve requests over TLS on, e.g. :443")
+	cmd.Flags().String(tlsCertFlag, "", "Path to a TLS certificate file")
+	cmd.Flags().String(tlsKeyFlag, "", "Path to a TLS private key file")
+	cmd.Flags().String(tlsClientCAFlag, "", "Path to a PEM file of CAs, requires client certificates signed by them")
+	cmd.Flags().String(unixSocketFlag, "", "Listen on the Unix domain socket instead of the port")
+	cmd.Flags().StringP(databaseFlag, "d", getDefaultDatabase(), "Path to MaxMind database, defaults to $"+databaseEnv)
+	cmd.Flags().String(ipv6DatabaseFlag, "", "Path to MaxMind database used for IPv6 addresses")
+	cmd.Flags().Int(badRequestFlag, http.StatusBadRequest, "Status of responses to requests with unparseable client addresses")
+	cmd.Flags().String(badRequestMsgFlag, "", "Body of responses to requests with unparseable client addresses")
+	cmd.Flags().StringSlice(allowMethodsFlag, nil, "List of proxied HTTP methods, other methods are rejected with 405, e.g. GET,HEAD")
+	cmd.Flags().StringArray(blockHeaderFlag, nil, "Block requests with a header matching a pattern, e.g. X-Scanner=^bot, repeatable")
+	cmd.Flags().Bool(blockEmptyUAFlag, false, "Block requests without a User-Agent header")
+	cmd.Flags().StringSlice(blockPTRFlag, nil, "List of reverse DNS name patterns of blocked clients, e.g. *.amazonaws.com")
+	cmd.Flags().Duration(waitForDbFlag, 0, "Time to wait at startup for the database to appear")
+	cmd.Flags().Int(maxHeaderFlag, 0, "Maximum size of request headers in bytes, defaults to 1MB")
+	cmd.Flags().String(asnDatabaseFlag, "", "Path to MaxMind ASN database")
+	cmd.Flags().UintSlice(blockASNsFlag, nil, "List of blocked autonomous system numbers (requires --"+asnDatabaseFlag+" or an Enterprise database)")
+	cmd.Flags().BoolP(watchFlag, "w", false, "Watch for database file changes and reload automatically")
+	cmd.Flags().StringP(targetFlag, "t", "", "Target URL")
+	cmd.Flags().StringToInt(targetsFlag, nil, "Distribute requests between targets by weights instead of --"+targetFlag+", e.g. http://primary=90,http://canary=10")
+	cmd.Flags().Bool(lazyTargetFlag, false, "Respond with 503 instead of 502 while the target host does not resolve")
+	cmd.Flags().Bool(strictTargetFlag, false, "Fail to start when the target host does not resolve")
+	cmd.Flags().StringP(messageFlag, "m", "", "Message to show when request is blocked")
+	cmd.Flags().StringP(redirectFlag, "r", "", "Redirect to the specified URL")
+	cmd.Flags().Int(redirectCodeFlag, http.StatusTemporaryRedirect, "Status code of the redirect: 301, 302, 303, 307 or 308")
+	cmd.Flags().Bool(preservePathFlag, false, "Append the path of a blocked request to the redirect URL")
+	cmd.Flags().String(softBlockFlag, "", "Pass requests which would be blocked to the target, tagged with the specified header")
+	cmd.Flags().String(blockBackendFlag, "", "Serve blocked requests by the backend at the specified URL, e.g. a landing page")
+	cmd.Flags().String(honeypotFlag, "", "Forward blocked requests to the specified honeypot URL")
+	cmd.Flags().StringSlice(corsOriginsFlag, nil, "Origins allowed in CORS headers of responses to blocked preflight requests, * for any")
+	cmd.Flags().Bool(noContentFlag, false, "Respond to blocked requests with 204 No Content")
+	cmd.Flags().StringP(fileFlag, "f", "", "File or http(s) URL of a page to show when request is blocked")
+	cmd.Flags().Duration(fileRefreshFlag, 0, "Interval of fetching the page again when --"+fileFlag+" is a URL")
+	cmd.Flags().StringP(allowFlag, "a", "", "List of allowed countries, EU stands for the European Union member states")
+	cmd.Flags().StringP(blockFlag, "b", "", "List of blocked countries, EU stands for the European Union member states")
+	cmd.Flags().Bool(allowEUFlag, false, "Allow countries of the European Union in addition to the --"+allowFlag+" list")
+	cmd.Flags().Bool(blockEUFlag, false, "Block countries of the European Union")
+	cmd.Flags().StringArray(scheduleFlag, nil, "Allow a country only during time windows, e.g. US=09:00-17:00,19:00-21:00@America/New_York")
+	cmd.Flags().String(countrySourceFlag, "country", "Country used for filtering: country, registered or represented")
+	cmd.Flags().Bool(blockAnonFlag, false, "Block anonymous proxies (requires a GeoIP2 Country, City or Enterprise database)")
+	cmd.Flags().Bool(blockHostingFlag, false, "Block hosting providers (requires a GeoIP2 Enterprise database)")
+	cmd.Flags().String(selfTestFlag, "", "List of ip=country pairs resolved at startup to verify the database")
+	cmd.Flags().Bool(strictFlag, false, "Fail to start when the allowed or blocked lists contain unknown countries")
+	cmd.Flags().Duration(tarpitFlag, 0, "Delay responses to blocked requests for the specified duration, shorter than the write timeout")
+	cmd.Flags().Int(tarpitMaxFlag, 100, "Maximum number of simultaneously delayed blocked requests")
+	cmd.Flags().String(allowNetworksFlag, "", "List of allowed networks (CIDR, start-end range or address)")
+	cmd.Flags().Bool(viaHeaderFlag, false, "Add a Via header to responses of the target")
+	cmd.Flags().Duration(overloadFlag, 0, "Respond with 429 while the target signals overload, for the duration unless it sends Retry-After")
+	cmd.Flags().Int(maxIdleConnsFlag, 0, "Maximum number of idle connections to the target, defaults to 100")
+	cmd.Flags().Int(maxConnsFlag, 0, "Maximum number of connections to the target, not limited by default")
+	cmd.Flags().Duration(idleTimeoutFlag, 0, "Time an idle connection to the target is kept open, defaults to 90s")
+	cmd.Flags().String(rulesURLFlag, "", "URL of filtering rules in the print-rules JSON format, fetched periodically")
+	cmd.Flags().Duration(rulesRefreshFlag, time.Minute, "Interval of fetching rules from --"+rulesURLFlag)
+	cmd.Flags().StringToString(mapCountryFlag, nil, "Treat resolved countries as other countries, e.g. XK=RS")
+	cmd.Flags().StringToInt(countryRateFlag, nil, "Maximum numbers of requests per second from countries, e.g. US=10,CN=5")
+	cmd.Flags().Bool(networksFirstFlag, false, "Allow requests from allowed networks regardless of country rules")
+	cmd.Flags().String(blockNetworksFlag, "", "List of blocked networks (CIDR, start-end range or address)")
+	cmd.Flags().String(logFileFlag, "", "Write logs to the specified file instead of stderr")
+	cmd.Flags().Int(logMaxSizeFlag, 100, "Maximum size of a log file in megabytes before it gets rotated")
+	cmd.Flags().Int(logMaxBackupsFlag, 3, "Maximum number of rotated log files to keep")
+	cmd.Flags().Int(logMaxAgeFlag, 28, "Maximum number of days to keep rotated log files")
+	cmd.Flags().String(accessLogFlag, "", "Write an access log in the Combined Log Format to the specified file, '-' for stdout")
+	cmd.Flags().String(adminFlag, "", "Address of the admin server with /healthz and /reload endpoints, e.g. 127.0.0.1:8081")
+	cmd.Flags().String(xffStrategyFlag, "", "Client address in X-Forwarded-For: leftmost, rightmost-trusted or an index, negative indexes count from the right")
+	cmd.Flags().StringSlice(trustedProxyFlag, nil, "List of trusted proxy networks, client address headers are only honored in requests from them")
+	cmd.Flags().String(lookupAPIFlag, "", "Serve geo data of addresses as JSON on the specified path instead of proxying, e.g. /lookup")
+	cmd.Flags().Int(lookupRateFlag, 0, "Maximum number of lookup API requests per second from a client, defaults to 10")
+	cmd.Flags().String(statsdFlag, "", "Send metrics to a StatsD server at host:port over UDP")
+	cmd.Flags().String(debugStreamFlag, "", "Stream decisions as server-sent events on the specified path of the admin server, e.g. /debug/decisions")
+	cmd.Flags().String(versionPathFlag, "", "Serve the build version as JSON on the specified path, e.g. /version")
+	cmd.Flags().BoolP(quietFlag, "q", false, "Log errors only")
+	cmd.Flags().Bool(anonymizeFlag, false, "Mask client IP addresses in logs")
+	cmd.Flags().Bool(noGeoHeaderFlag, false, "Do not pass the country of a client to the target")
+	cmd.Flags().StringSlice(headerNameFlag, nil, "Names of headers to pass the country code to the target, defaults to X-Geo-Country")
+	cmd.Flags().String(geoJSONFlag, "", "Pass geo data to the target as a base64-encoded JSON in the specified header")
+	cmd.Flags().Bool(rewriteFlag, false, "Rewrite redirects to the target host so they point to the proxy host")
+	cmd.Flags().Int(retriesFlag, 0, "Number of retries of GET and HEAD requests when the target is unreachable")
+	cmd.Flags().Int(breakerFlag, 0, "Number of consecutive target failures after which requests are rejected with 503")
+	cmd.Flags().Duration(breakerResetFlag, 30*time.Second, "Time to reject requests before probing the target again")
+	cmd.Flags().Duration(lookupTimeoutFlag, 0, "Maximum time to wait for a country lookup, treated as unresolved when exceeded")
+	cmd.Flags().Bool(serverTimingFlag, false, "Add a Server-Timing header with the country lookup duration to responses")
+	cmd.Flags().Bool(prefixCacheFlag, false, "Cache lookup results by /24 IPv4 and /48 IPv6 prefixes")
+	cmd.Flags().Duration(cacheTTLFlag, 0, "Expire cached lookup results after the specified time, enables the prefix cache")
+	cmd.Flags().String(cacheWarmupFlag, "", "File with addresses to resolve into the lookup cache at startup and after reloads")
+
+	_ = cmd.MarkFlagFilename(databaseFlag, "mmdb", "gz")
+	_ = cmd.MarkFlagFilename(ipv6DatabaseFlag, "mmdb", "gz")
+	_ = cmd.MarkFlagFilename(asnDatabaseFlag, "mmdb")
+}
+
 func init() {
-	startProxyCmd.Flags().UintP(portFlag, "p", 80, "port")
-	startProxyCmd.Flags().StringP(databaseFlag, "d", "GeoLite2-Country.mmdb", "Path to MaxMind database")
-	startProxyCmd.Flags().BoolP(watchFlag, "w", false, "Watch for database file changes and reload automatically")
-	startProxyCmd.Flags().StringP(targetFlag, "t", "", "Target URL")
-	startProxyCmd.Flags().StringP(messageFlag, "m", "", "Message to show when request is blocked")
-	startProxyCmd.Flags().StringP(redirectFlag, "r", "", "Redirect to the specified URL")
-	startProxyCmd.Flags().StringP(fileFlag, "f", "", "File to show when request is blocked")
-	startProxyCmd.Flags().StringP(allowFlag, "a", "", "List of allowed countries")
-	startProxyCmd.Flags().StringP(blockFlag, "b", "", "List of blocked countries")
-
-	_ = startProxyCmd.MarkFlagFilename(databaseFlag, "mmdb")
-	_ = startProxyCmd.MarkFlagRequired(targetFlag)
+	startProxyCmd.Version = versionString()
+	addStartProxyFlags(startProxyCmd)
 }
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"bytes"
+	"geofilter/proxy"
+	"geofilter/proxy/proxytest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestGetCountriesOpt(t *testing.T) {
+	opt, ignored, err := getCountriesOpt("Germany,Atlantis", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt == nil {
+		t.Error("rules of known countries are not returned")
+	}
+	if !reflect.DeepEqual(ignored, []string{"Atlantis"}) {
+		t.Errorf("expected Atlantis to be reported as ignored, got %v", ignored)
+	}
+
+	if _, _, err := getCountriesOpt("Germany,Atlantis", "", true); err == nil {
+		t.Error("unknown country is accepted in the strict mode")
+	}
+	if _, _, err := getCountriesOpt("", "Atlantis", false); err == nil {
+		t.Error("list of only unknown countries is accepted")
+	}
+
+	if _, ignored, err := getCountriesOpt("", "France", true); err != nil || len(ignored) > 0 {
+		t.Errorf("valid list is rejected: %v, ignored %v", err, ignored)
+	}
+}
+
+func TestDefaultDatabase(t *testing.T) {
+	previous, set := os.LookupEnv(databaseEnv)
+	defer func() {
+		if set {
+			_ = os.Setenv(databaseEnv, previous)
+		} else {
+			_ = os.Unsetenv(databaseEnv)
+		}
+	}()
+
+	_ = os.Unsetenv(databaseEnv)
+	if database := getDefaultDatabase(); database != defaultDatabase {
+		t.Errorf("expected %s without the environment variable, got %s", defaultDatabase, database)
+	}
+
+	_ = os.Setenv(databaseEnv, "  ")
+	if database := getDefaultDatabase(); database != defaultDatabase {
+		t.Errorf("expected %s with a blank environment variable, got %s", defaultDatabase, database)
+	}
+
+	_ = os.Setenv(databaseEnv, " /var/lib/geoip/country.mmdb ")
+	if database := getDefaultDatabase(); database != "/var/lib/geoip/country.mmdb" {
+		t.Errorf("environment variable is not used, got %s", database)
+	}
+
+	cmd := &cobra.Command{}
+	addBenchFlags(cmd)
+	if database, _ := cmd.Flags().GetString(databaseFlag); database != "/var/lib/geoip/country.mmdb" {
+		t.Errorf("environment variable is not used as the flag default, got %s", database)
+	}
+}
+
+func runStartProxyCmd(args ...string) (string, error) {
+	cmd := &cobra.Command{Use: "geofilter", RunE: startProxy, SilenceUsage: true, SilenceErrors: true}
+	addStartProxyFlags(cmd)
+
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	cmd.SetArgs(args)
+
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestTestConfig(t *testing.T) {
+	database := writeBenchDatabase(t, false)
+
+	out, err := runStartProxyCmd("--test-config", "--quiet", "-d", database, "-a", "US", "-t", "http://127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("valid configuration is rejected: %v", err)
+	}
+	if !strings.Contains(out, "configuration is valid") {
+		t.Errorf("valid configuration is not reported, got %q", out)
+	}
+
+	invalid := [][]string{
+		{"-d", database, "-a", "US", "-b", "RU", "-t", "http://127.0.0.1:1"},
+		{"-d", database, "-a", "US", "-t", "127.0.0.1"},
+		{"-d", database + ".missing", "-a", "US", "-t", "http://127.0.0.1:1"},
+	}
+	for _, args := range invalid {
+		if _, err := runStartProxyCmd(append([]string{"--test-config", "--quiet"}, args...)...); err == nil {
+			t.Errorf("invalid configuration %v is accepted", args)
+		}
+	}
+}
+
+func TestEUToken(t *testing.T) {
+	known, unknown := parseCountries("EU,GB,Switzerland")
+	if len(unknown) > 0 {
+		t.Errorf("unexpected unknown countries %v", unknown)
+	}
+	if len(known) != len(proxy.EUMembers)+2 {
+		t.Errorf("expected EU members, GB and CH, got %v", known)
+	}
+
+	opt, _, err := getCountriesOpt("EU,GB,CH", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := proxytest.Countries{"1.1.1.1": "DE", "2.2.2.2": "GB", "3.3.3.3": "CH", "4.4.4.4": "US", "5.5.5.5": "NO"}
+	expected := map[string]bool{"1.1.1.1": true, "2.2.2.2": true, "3.3.3.3": true, "4.4.4.4": false, "5.5.5.5": false}
+	for addr, allowed := range expected {
+		decision, _, err := proxytest.Decide(resolver, proxytest.NewRequest(addr), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed != allowed {
+			t.Errorf("expected %s from %s to be allowed: %v", resolver[addr], addr, allowed)
+		}
+	}
+}
+
+func TestTransportFlags(t *testing.T) {
+	cmd := &cobra.Command{Use: "geofilter"}
+	addStartProxyFlags(cmd)
+
+	if transport := getTransport(cmd); transport != nil {
+		t.Error("transport is configured without flags")
+	}
+
+	if err := cmd.ParseFlags([]string{"--max-idle-conns", "10", "--max-conns-per-host", "20", "--idle-timeout", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := getTransport(cmd)
+	if transport == nil {
+		t.Fatal("transport is not configured by flags")
+	}
+	if transport.MaxIdleConns != 10 || transport.MaxConnsPerHost != 20 || transport.IdleConnTimeout != time.Minute {
+		t.Errorf("flags are not applied: %d idle connections, %d connections per host, %s idle timeout",
+			transport.MaxIdleConns, transport.MaxConnsPerHost, transport.IdleConnTimeout)
+	}
+}
@@ -0,0 +1,15 @@
+package commands
+
+import "fmt"
+
+// Build information, it is set at build time with
+// -ldflags "-X geofilter/commands.BuildVersion=... -X geofilter/commands.BuildCommit=... -X geofilter/commands.BuildDate=..."
+var (
+	BuildVersion = "dev"
+	BuildCommit  = "unknown"
+	BuildDate    = "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", BuildVersion, BuildCommit, BuildDate)
+}
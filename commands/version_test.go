@@ -0,0 +1,15 @@
+package commands
+
+import "testing"
+
+func TestVersionString(t *testing.T) {
+	defer func(version, commit, date string) {
+		BuildVersion, BuildCommit, BuildDate = version, commit, date
+	}(BuildVersion, BuildCommit, BuildDate)
+
+	BuildVersion, BuildCommit, BuildDate = "1.2.3", "abcdef", "2020-03-01"
+
+	if version := versionString(); version != "1.2.3 (commit abcdef, built 2020-03-01)" {
+		t.Errorf("unexpected version %s", version)
+	}
+}
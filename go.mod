@@ -8,5 +8,7 @@ require (
 	github.com/oschwald/geoip2-golang v1.4.0
 	github.com/pkg/errors v0.8.1
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.3
 	go.uber.org/zap v1.14.1
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
@@ -0,0 +1,305 @@
+// Package mmdbtest writes small MaxMind databases for tests.
+package mmdbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	recordSize    = 24
+	maxRecord     = 1<<recordSize - 1
+	separatorSize = 16
+)
+
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Country returns a record of a country database for an ISO country code.
+func Country(isoCode string) map[string]interface{} {
+	return map[string]interface{}{
+		"country": map[string]interface{}{
+			"iso_code": isoCode,
+		},
+	}
+}
+
+// Write writes a database of dbType, e.g. GeoLite2-Country, with records keyed by networks in CIDR notation.
+// IPv4 networks are stored in the IPv4 subtree of the IPv6 database, more specific networks override less specific ones.
+// Supported values are maps with string keys, slices, strings, booleans, float64, int, uint16, uint32 and uint64.
+func Write(path string, dbType string, records map[string]interface{}) error {
+	data, err := Build(dbType, records)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Build returns contents of a database, see Write.
+func Build(dbType string, records map[string]interface{}) ([]byte, error) {
+	type network struct {
+		ip     net.IP
+		prefix int
+		value  interface{}
+	}
+
+	networks := make([]network, 0, len(records))
+	for cidr, value := range records {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network '%s'", cidr)
+		}
+
+		ip := ipNet.IP.To16()
+		ones, _ := ipNet.Mask.Size()
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			// IPv4 networks are stored under ::/96
+			ip = append(make(net.IP, 12), ip4...)
+			ones += 96
+		}
+		networks = append(networks, network{ip: ip, prefix: ones, value: value})
+	}
+
+	// less specific networks are inserted first, so more specific ones are split out of them
+	sort.SliceStable(networks, func(i, j int) bool {
+		if networks[i].prefix != networks[j].prefix {
+			return networks[i].prefix < networks[j].prefix
+		}
+		return bytes.Compare(networks[i].ip, networks[j].ip) < 0
+	})
+
+	var data dataSection
+	root := &node{}
+	for _, n := range networks {
+		offset, err := data.add(n.value)
+		if err != nil {
+			return nil, err
+		}
+
+		root.insert(n.ip, n.prefix, offset)
+	}
+
+	nodes := root.number()
+	if len(nodes)+separatorSize+len(data.buf) > maxRecord {
+		return nil, errors.New("database is too large")
+	}
+
+	var buf bytes.Buffer
+	nodeCount := uint32(len(nodes))
+	for _, n := range nodes {
+		for _, child := range n.children {
+			writeRecord(&buf, child.record(nodeCount))
+		}
+	}
+	buf.Write(make([]byte, separatorSize))
+	buf.Write(data.buf)
+	buf.Write(metadataStartMarker)
+
+	var metadata dataSection
+	if _, err := metadata.add(map[string]interface{}{
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(recordSize),
+		"ip_version":                  uint16(6),
+		"database_type":               dbType,
+		"languages":                   []interface{}{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"description":                 map[string]interface{}{"en": "geofilter test database"},
+	}); err != nil {
+		return nil, err
+	}
+	buf.Write(metadata.buf)
+
+	return buf.Bytes(), nil
+}
+
+// child is a record of a search tree node, it is either another node, a data offset or empty.
+type child struct {
+	node    *node
+	offset  int
+	hasData bool
+}
+
+func (c child) record(nodeCount uint32) uint32 {
+	switch {
+	case c.node != nil:
+		return uint32(c.node.index)
+	case c.hasData:
+		return nodeCount + separatorSize + uint32(c.offset)
+	default:
+		return nodeCount
+	}
+}
+
+type node struct {
+	index    int
+	children [2]child
+}
+
+func (n *node) insert(ip net.IP, prefix int, offset int) {
+	current := n
+	for depth := 0; depth < prefix; depth++ {
+		bit := (ip[depth/8] >> (7 - uint(depth%8))) & 1
+		c := &current.children[bit]
+
+		if depth == prefix-1 {
+			*c = child{offset: offset, hasData: true}
+			return
+		}
+
+		if c.node == nil {
+			// a network inside of a less specific one inherits its record
+			next := &node{}
+			next.children[0] = *c
+			next.children[1] = *c
+			c.node = next
+			c.hasData = false
+		}
+		current = c.node
+	}
+}
+
+// number assigns indexes to the nodes in breadth-first order and returns them.
+func (n *node) number() []*node {
+	nodes := []*node{n}
+	for i := 0; i < len(nodes); i++ {
+		nodes[i].index = i
+		for _, c := range nodes[i].children {
+			if c.node != nil {
+				nodes = append(nodes, c.node)
+			}
+		}
+	}
+
+	return nodes
+}
+
+func writeRecord(buf *bytes.Buffer, record uint32) {
+	buf.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+}
+
+// dataSection encodes values in the MaxMind DB data format.
+type dataSection struct {
+	buf []byte
+}
+
+const (
+	typeString = 2
+	typeDouble = 3
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+	typeArray  = 11
+	typeBool   = 14
+)
+
+func (d *dataSection) add(value interface{}) (int, error) {
+	offset := len(d.buf)
+	return offset, d.encode(value)
+}
+
+func (d *dataSection) encode(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		d.control(typeString, len(v))
+		d.buf = append(d.buf, v...)
+	case float64:
+		d.control(typeDouble, 8)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+		d.buf = append(d.buf, b[:]...)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		d.control(typeBool, size)
+	case int:
+		if v < 0 || v > math.MaxUint32 {
+			return errors.Errorf("integer %d is out of range", v)
+		}
+		d.uint(typeUint32, uint64(v))
+	case uint16:
+		d.uint(typeUint16, uint64(v))
+	case uint32:
+		d.uint(typeUint32, uint64(v))
+	case uint64:
+		d.uint(typeUint64, v)
+	case []interface{}:
+		d.control(typeArray, len(v))
+		for _, item := range v {
+			if err := d.encode(item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		d.control(typeArray, len(v))
+		for _, item := range v {
+			_ = d.encode(item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		d.control(typeMap, len(v))
+		for _, key := range keys {
+			_ = d.encode(key)
+			if err := d.encode(v[key]); err != nil {
+				return errors.Wrapf(err, "invalid value of '%s'", key)
+			}
+		}
+	default:
+		return errors.Errorf("unsupported value type %T", value)
+	}
+
+	return nil
+}
+
+func (d *dataSection) uint(typeNum int, v uint64) {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+
+	d.control(typeNum, len(b))
+	d.buf = append(d.buf, b...)
+}
+
+func (d *dataSection) control(typeNum int, size int) {
+	var sizeBits byte
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 285:
+		sizeBits = 29
+		sizeBytes = []byte{byte(size - 29)}
+	case size < 65821:
+		sizeBits = 30
+		size -= 285
+		sizeBytes = []byte{byte(size >> 8), byte(size)}
+	default:
+		sizeBits = 31
+		size -= 65821
+		sizeBytes = []byte{byte(size >> 16), byte(size >> 8), byte(size)}
+	}
+
+	if typeNum <= 7 {
+		d.buf = append(d.buf, byte(typeNum<<5)|sizeBits)
+	} else {
+		d.buf = append(d.buf, sizeBits, byte(typeNum-7))
+	}
+	d.buf = append(d.buf, sizeBytes...)
+}
@@ -0,0 +1,72 @@
+package mmdbtest
+
+import (
+	"net"
+	"testing"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+func TestBuild(t *testing.T) {
+	data, err := Build("GeoLite2-Country", map[string]interface{}{
+		"1.2.0.0/16":    Country("US"),
+		"1.2.3.0/24":    Country("DE"),
+		"2001:db8::/32": Country("FR"),
+		"5.6.7.8/32": map[string]interface{}{
+			"country": map[string]interface{}{
+				"iso_code":             "NL",
+				"geoname_id":           2750405,
+				"is_in_european_union": true,
+				"names":                map[string]interface{}{"en": "Netherlands"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := geoip2.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if dbType := db.Metadata().DatabaseType; dbType != "GeoLite2-Country" {
+		t.Errorf("unexpected database type %s", dbType)
+	}
+
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"1.2.0.1", "US"},
+		{"1.2.3.4", "DE"},
+		{"1.2.4.1", "US"},
+		{"1.3.0.1", ""},
+		{"2001:db8::1", "FR"},
+		{"2001:db9::1", ""},
+		{"::ffff:1.2.3.4", "DE"},
+		{"5.6.7.8", "NL"},
+	}
+
+	for _, test := range tests {
+		record, err := db.Country(net.ParseIP(test.ip))
+		if err != nil {
+			t.Fatalf("%s: %v", test.ip, err)
+		}
+		if record.Country.IsoCode != test.expected {
+			t.Errorf("%s is resolved as '%s', expected '%s'", test.ip, record.Country.IsoCode, test.expected)
+		}
+	}
+
+	record, _ := db.Country(net.ParseIP("5.6.7.8"))
+	if !record.Country.IsInEuropeanUnion || record.Country.GeoNameID != 2750405 || record.Country.Names["en"] != "Netherlands" {
+		t.Errorf("unexpected record %+v", record.Country)
+	}
+}
+
+func TestBuildInvalidNetwork(t *testing.T) {
+	if _, err := Build("GeoLite2-Country", map[string]interface{}{"1.2.3.4": Country("US")}); err == nil {
+		t.Error("network without a prefix is accepted")
+	}
+}
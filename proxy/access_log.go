@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+type accessLog struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+// responseRecorder keeps a status code and a size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(data)
+	r.size += n
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// WithCLFAccessLog is used to write an access log in the Combined Log Format.
+// A client's country is appended to every line as an extra quoted field.
+func WithCLFAccessLog(writer io.Writer) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.accessLog = &accessLog{writer: writer}
+		return proxy, nil
+	}
+}
+
+func clfField(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+
+	return value
+}
+
+func clfQuoted(value string) string {
+	return `"` + strings.Replace(clfField(value), `"`, `\"`, -1) + `"`
+}
+
+func (p *geoProxy) writeAccessLog(req *http.Request, rec *responseRecorder, started time.Time) {
+	host := "-"
+	if ip := getIP(p.clientAddr(req)); ip != nil {
+		if p.anonymizeIP {
+			ip = anonymizeIP(ip)
+		}
+		host = ip.String()
+	}
+
+	user := "-"
+	if username, _, ok := req.BasicAuth(); ok && len(username) > 0 {
+		user = username
+	}
+
+	size := "-"
+	if rec.size > 0 {
+		size = fmt.Sprint(rec.size)
+	}
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	decision, _ := DecisionFromContext(req.Context())
+
+	line := fmt.Sprintf("%s - %s [%s] %s %d %s %s %s %s\n",
+		host,
+		user,
+		started.Format(clfTimeFormat),
+		clfQuoted(fmt.Sprintf("%s %s %s", req.Method, req.RequestURI, req.Proto)),
+		status,
+		size,
+		clfQuoted(req.Referer()),
+		clfQuoted(req.UserAgent()),
+		clfQuoted(decision.Country),
+	)
+
+	p.accessLog.lock.Lock()
+	defer p.accessLog.lock.Unlock()
+
+	if _, err := io.WriteString(p.accessLog.writer, line); err != nil {
+		p.logger.Warn("failed to write access log")
+	}
+}
+
+func (p *geoProxy) withAccessLog(handler http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		started := p.clock.Now()
+		rec := &responseRecorder{ResponseWriter: res}
+		req = WithDecisionRecorder(req)
+
+		handler(rec, req)
+
+		p.writeAccessLog(req, rec, started)
+	}
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCLFAccessLog(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		_, _ = res.Write([]byte("hello"))
+	}))
+	defer backend.Close()
+
+	out := &bytes.Buffer{}
+	clock := newFakeClock()
+	clock.Set(time.Date(2020, time.March, 1, 10, 20, 30, 0, time.UTC))
+	p, err := New(0, "", backend.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+		WithClock(clock),
+		WithCLFAccessLog(out),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	allowed := newTestRequest("1.1.1.1")
+	allowed.RequestURI = "/index.html?page=1"
+	allowed.Header.Set("Referer", "https://example.com/")
+	allowed.Header.Set("User-Agent", `curl "7.68"`)
+	allowed.SetBasicAuth("frank", "secret")
+	p.Handler().ServeHTTP(httptest.NewRecorder(), allowed)
+
+	blocked := newTestRequest("2.2.2.2")
+	blocked.RequestURI = "/"
+	p.Handler().ServeHTTP(httptest.NewRecorder(), blocked)
+
+	expected := `1.1.1.1 - frank [01/Mar/2020:10:20:30 +0000] "GET /index.html?page=1 HTTP/1.1" 200 5 "https://example.com/" "curl \"7.68\"" "US"` + "\n" +
+		`2.2.2.2 - - [01/Mar/2020:10:20:30 +0000] "GET / HTTP/1.1" 403 - "-" "-" "RU"` + "\n"
+	if out.String() != expected {
+		t.Errorf("unexpected access log:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
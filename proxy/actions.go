@@ -0,0 +1,90 @@
+package proxy
+
+import "net/http"
+
+// actionWriter lets only the first action writing a response take effect.
+type actionWriter struct {
+	http.ResponseWriter
+	current   int
+	owner     int
+	written   bool
+	discarded http.Header
+}
+
+// owns reports whether the running action may write a response.
+func (w *actionWriter) owns() bool {
+	if !w.written {
+		w.written = true
+		w.owner = w.current
+	}
+	return w.owner == w.current
+}
+
+// Header returns headers of the response, or headers which are discarded
+// when the response has already been written by a previous action.
+func (w *actionWriter) Header() http.Header {
+	if w.written && w.owner != w.current {
+		if w.discarded == nil {
+			w.discarded = make(http.Header)
+		}
+		return w.discarded
+	}
+	return w.ResponseWriter.Header()
+}
+
+func (w *actionWriter) WriteHeader(status int) {
+	if w.owns() {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *actionWriter) Write(b []byte) (int, error) {
+	if w.owns() {
+		return w.ResponseWriter.Write(b)
+	}
+	return len(b), nil
+}
+
+// addAction appends an action to the actions run when request is blocked.
+func (p *geoProxy) addAction(action actionFunc) {
+	p.actions = append(p.actions, action)
+}
+
+// runActions runs the actions in sequence, only the first action writing a response takes effect.
+// Requests are blocked with 403 Forbidden when no action writes a response.
+func (p *geoProxy) runActions(res http.ResponseWriter, req *http.Request) {
+	w := &actionWriter{ResponseWriter: res}
+	for i, action := range p.actions {
+		w.current = i
+		action(w, req)
+	}
+
+	if !w.written {
+		defaultAction(res, req)
+	}
+}
+
+// WithActions is used to run several actions in sequence when request is blocked, e.g. to log a request,
+// set a header and redirect. The actions are appended to the block responses configured by other options,
+// e.g. WithRedirect or WithMessage, in the order of the options. Only the first action writing a response
+// takes effect, responses of the following actions are discarded. Requests are blocked with 403 Forbidden
+// when no action writes a response.
+func WithActions(actions ...func(http.ResponseWriter, *http.Request)) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(actions) == 0 {
+			return nil, configError(ErrInvalidOption, "actions are not specified")
+		}
+
+		for _, action := range actions {
+			if action == nil {
+				return nil, configError(ErrInvalidOption, "action must not be nil")
+			}
+		}
+
+		for _, action := range actions {
+			proxy.addAction(action)
+		}
+		proxy.blockResponse = "custom action"
+		return proxy, nil
+	}
+}
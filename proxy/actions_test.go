@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestActionsWithRedirect(t *testing.T) {
+	var logged []string
+	logAction := func(_ http.ResponseWriter, req *http.Request) {
+		logged = append(logged, req.RemoteAddr)
+	}
+	tagAction := func(res http.ResponseWriter, _ *http.Request) {
+		res.Header().Set("X-Blocked", "geo")
+	}
+	lateAction := func(res http.ResponseWriter, _ *http.Request) {
+		res.Header().Set("X-Late", "true")
+		res.WriteHeader(http.StatusTeapot)
+		_, _ = res.Write([]byte("late"))
+	}
+
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithActions(logAction, tagAction),
+		WithRedirect("https://example.com/blocked"),
+		WithActions(lateAction),
+	)
+
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if len(logged) != 1 {
+		t.Errorf("expected the request to be logged once, got %v", logged)
+	}
+	if res.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected %d, got %d", http.StatusTemporaryRedirect, res.Code)
+	}
+	if location := res.Header().Get("Location"); location != "https://example.com/blocked" {
+		t.Errorf("unexpected location %s", location)
+	}
+	if tag := res.Header().Get("X-Blocked"); tag != "geo" {
+		t.Errorf("header set before the redirect is missing, got '%s'", tag)
+	}
+	if late := res.Header().Get("X-Late"); len(late) > 0 {
+		t.Error("header set after the redirect is written")
+	}
+	if strings.Contains(res.Body.String(), "late") {
+		t.Errorf("body written after the redirect is not discarded: %s", res.Body.String())
+	}
+}
+
+func TestActionsWithoutResponse(t *testing.T) {
+	called := false
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithActions(func(http.ResponseWriter, *http.Request) {
+			called = true
+		}),
+	)
+
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if !called {
+		t.Error("action is not called")
+	}
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected %d when no action writes a response, got %d", http.StatusForbidden, res.Code)
+	}
+}
+
+func TestActionsValidation(t *testing.T) {
+	if _, err := New(0, "", "", WithActions()); err == nil {
+		t.Error("empty list of actions is accepted")
+	}
+	if _, err := New(0, "", "", WithActions(nil)); err == nil {
+		t.Error("nil action is accepted")
+	}
+
+	noop := func(http.ResponseWriter, *http.Request) {}
+	if _, err := New(0, "", "", WithActions(noop), WithNoContentBlock()); err == nil {
+		t.Error("actions are combined with a no content block")
+	}
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// WithAdminListener is used to serve administrative endpoints on a separate address, e.g. 127.0.0.1:8081,
+// so they are not exposed on the public port. The admin server provides:
+//
+//	/healthz - responds with 200 when a GeoIP database is loaded
+//	/reload  - reloads a GeoIP database on POST requests
+//	/stats   - responds with numbers of handled requests as JSON
+//
+// The version endpoint is moved to the admin server as well.
+func WithAdminListener(addr string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, configError(ErrInvalidOption, "invalid admin address '%s'", addr)
+		}
+
+		proxy.adminAddr = addr
+		proxy.enableDbLock()
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) healthHandler(res http.ResponseWriter, _ *http.Request) {
+	p.dbLock.RLock()
+	loaded := p.db != nil || p.customResolver
+	p.dbLock.RUnlock()
+
+	if !loaded {
+		res.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	_, _ = res.Write([]byte("ok"))
+}
+
+func (p *geoProxy) reloadHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		res.Header().Set("Allow", http.MethodPost)
+		res.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := p.reloadGeoDb(); err != nil {
+		p.logger.Error("failed to reload Geo DB",
+			zap.Error(err),
+		)
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p.logger.Info("Geo DB is reloaded")
+	_, _ = res.Write([]byte("ok"))
+}
+
+func (p *geoProxy) statsHandler(res http.ResponseWriter, _ *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(res).Encode(p.Stats())
+}
+
+// setupAdminEndpoints registers administrative endpoints and returns the server to serve them.
+// It returns nil when the endpoints are served by the main server.
+func (p *geoProxy) setupAdminEndpoints() *http.Server {
+	mux := p.mux
+	var server *http.Server
+
+	if len(p.adminAddr) > 0 {
+		mux = http.NewServeMux()
+		mux.HandleFunc("/healthz", p.healthHandler)
+		mux.HandleFunc("/reload", p.reloadHandler)
+		mux.HandleFunc("/stats", p.statsHandler)
+
+		if len(p.decisionStreamPath) > 0 {
+			mux.HandleFunc(p.decisionStreamPath, p.decisionStreamHandler)
+		}
+
+		server = &http.Server{
+			Addr:              p.adminAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: p.timeouts.readHeader,
+			ReadTimeout:       p.timeouts.read,
+			WriteTimeout:      p.timeouts.write,
+			IdleTimeout:       p.timeouts.idle,
+			MaxHeaderBytes:    p.maxHeaderBytes,
+		}
+	}
+
+	if len(p.versionPath) > 0 {
+		mux.HandleFunc(p.versionPath, p.versionHandler)
+	}
+
+	return server
+}
+
+func (p *geoProxy) startAdminServer(server *http.Server) error {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return errors.Errorf("Failed to start admin server: %v\n", err)
+	}
+	// the address is updated with a port chosen by the system, e.g. for 127.0.0.1:0
+	server.Addr = listener.Addr().String()
+
+	p.logger.Info("starting admin server",
+		zap.String("addr", server.Addr),
+	)
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			p.logger.Error("admin server has failed",
+				zap.Error(err),
+			)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"encoding/json"
+	"geofilter/internal/mmdbtest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveAdmin starts the admin server of a proxy and returns its base URL.
+func serveAdmin(t *testing.T, p *geoProxy) string {
+	t.Helper()
+
+	server := p.setupAdminEndpoints()
+	if server == nil {
+		t.Fatal("admin server is not created")
+	}
+	if err := p.startAdminServer(server); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	return "http://" + server.Addr
+}
+
+func TestAdminEndpointsOnSeparateListener(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.Header().Set("X-Backend", "yes")
+		res.WriteHeader(http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	p, err := New(0, "", backend.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"127.0.0.1": "US"})),
+		WithAdminListener("127.0.0.1:0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	admin := serveAdmin(t, p)
+	p.setupPublicEndpoints()
+	public := httptest.NewServer(p.mux)
+	defer public.Close()
+
+	for _, path := range []string{"/healthz", "/reload", "/stats"} {
+		res, err := http.Get(public.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.Header.Get("X-Backend") != "yes" {
+			t.Errorf("%s is served on the public port", path)
+		}
+
+		res, err = http.Get(admin + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode == http.StatusNotFound {
+			t.Errorf("%s is not served on the admin port", path)
+		}
+	}
+
+	res, err := http.Get(admin + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	stats := Stats{}
+	if err := json.NewDecoder(res.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Allowed != 3 {
+		t.Errorf("expected 3 allowed requests in stats, got %+v", stats)
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "country.mmdb")
+	if err := mmdbtest.Write(path, "GeoLite2-Country", map[string]interface{}{"1.1.1.0/24": mmdbtest.Country("US")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the database is not loaded at startup because of the custom resolver
+	p, err := New(0, path, "",
+		WithQuiet(),
+		WithResolver(countries(nil)),
+		WithAdminListener("127.0.0.1:0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	admin := serveAdmin(t, p)
+
+	res, err := http.Get(admin + "/reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed || res.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("expected GET /reload to be rejected, got %d", res.StatusCode)
+	}
+
+	res, err = http.Post(admin+"/reload", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("reload has failed with %d: %s", res.StatusCode, body)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	res, err = http.Post(admin+"/reload", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected reload of a missing database to fail, got %d", res.StatusCode)
+	}
+
+	res, err = http.Get(admin + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected the proxy to stay healthy after a failed reload, got %d", res.StatusCode)
+	}
+}
+
+func TestEndpointsDoNotUseDefaultServeMux(t *testing.T) {
+	// endpoints of several proxies in one process must not conflict
+	for i := 0; i < 2; i++ {
+		p := openTestProxy(t,
+			WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+			WithVersionEndpoint("/version"),
+		)
+		p.setupAdminEndpoints()
+		p.setupPublicEndpoints()
+
+		req := newTestRequest("1.1.1.1")
+		req.URL.Path = "/version"
+		if _, pattern := p.mux.Handler(req); pattern != "/version" {
+			t.Errorf("version endpoint is not registered on the proxy mux, got '%s'", pattern)
+		}
+	}
+
+	for _, path := range []string{"/", "/version"} {
+		req := newTestRequest("1.1.1.1")
+		req.URL.Path = path
+		if _, pattern := http.DefaultServeMux.Handler(req); pattern != "" {
+			t.Errorf("%s is registered on http.DefaultServeMux", pattern)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package proxy
+
+// allowList is a union of allowed countries accumulated from allow options.
+type allowList struct {
+	countries map[string]bool
+	eu        bool
+}
+
+// extend returns a copy of the list to add countries to, so filters built from the list are not affected.
+func (l *allowList) extend() *allowList {
+	extended := &allowList{countries: make(map[string]bool)}
+	if l == nil {
+		return extended
+	}
+
+	for c := range l.countries {
+		extended.countries[c] = true
+	}
+	extended.eu = l.eu
+
+	return extended
+}
+
+func (l *allowList) allows(c countryInfo) bool {
+	return l.countries[c.isoCode] || (l.eu && c.inEU)
+}
+
+func (p *geoProxy) setAllowList(list *allowList) {
+	p.allowList = list
+	p.filter = list.allows
+}
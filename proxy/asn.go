@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+type asnFilterFunc func(asn uint) bool
+
+// WithASNDatabase is used to configure a GeoLite2/GeoIP2 ASN database to resolve
+// autonomous systems of clients. It is required by WithBlockedASNs unless the main
+// database is a GeoIP2 Enterprise database, which contains autonomous systems as well.
+func WithASNDatabase(path string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(path) == 0 {
+			return nil, configError(ErrDatabase, "ASN database path is not specified")
+		}
+
+		proxy.asnDbPath = path
+		return proxy, nil
+	}
+}
+
+// WithBlockedASNs is used to configure a proxy to block requests coming from a list of specified
+// autonomous systems. All other requests will be allowed. Requires WithASNDatabase
+// or an Enterprise database.
+func WithBlockedASNs(asns []uint) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(asns) == 0 {
+			return nil, configError(ErrNoRule, "blocked ASNs are not specified")
+		}
+
+		blockedASNs := make(map[uint]bool)
+		for _, asn := range asns {
+			blockedASNs[asn] = true
+		}
+
+		proxy.asnFilter = func(asn uint) bool {
+			return !blockedASNs[asn]
+		}
+
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) openASNDatabase() error {
+	if p.asnFilter == nil {
+		return nil
+	}
+
+	if len(p.asnDbPath) == 0 {
+		if p.db != nil && isEnterpriseDb(p.db) {
+			// autonomous systems are resolved from the main database
+			return nil
+		}
+
+		return configError(ErrDatabase, "blocking ASNs requires an ASN or an Enterprise database")
+	}
+
+	db, err := loadGeoDb(p.asnDbPath)
+	if err != nil {
+		return err
+	}
+
+	if !isEnterpriseDb(db) {
+		if _, err := db.ASN(net.IPv4zero); err != nil {
+			if _, ok := err.(geoip2.InvalidMethodError); ok {
+				_ = db.Close()
+				return configError(ErrDatabase, "'%s' is not an ASN database", p.asnDbPath)
+			}
+		}
+	}
+
+	p.asnDb = db
+	return nil
+}
+
+// blockedASN returns a reason to block an address when its autonomous system is blocked.
+// Addresses which are not found in the database are allowed.
+func (p *geoProxy) blockedASN(ip net.IP) *blockReason {
+	if p.asnFilter == nil {
+		return nil
+	}
+
+	asn := p.lookupASN(ip)
+	if asn == 0 || p.asnFilter(asn) {
+		return nil
+	}
+
+	return &blockReason{rule: "ASN", value: strconv.FormatUint(uint64(asn), 10)}
+}
+
+// lookupASN resolves the autonomous system number of an address, it returns 0 if it is unknown.
+// Without a separate ASN database the number is resolved from an Enterprise main database.
+func (p *geoProxy) lookupASN(ip net.IP) uint {
+	if p.asnDb != nil {
+		return asnOf(p.asnDb, ip)
+	}
+
+	p.dbLock.RLock()
+	defer p.dbLock.RUnlock()
+
+	db := p.dbFor(ip)
+	if db == nil {
+		return 0
+	}
+
+	return asnOf(db, ip)
+}
+
+func asnOf(db *geoip2.Reader, ip net.IP) uint {
+	if isEnterpriseDb(db) {
+		record, err := db.Enterprise(ip)
+		if err != nil {
+			return 0
+		}
+
+		return record.Traits.AutonomousSystemNumber
+	}
+
+	record, err := db.ASN(ip)
+	if err != nil {
+		return 0
+	}
+
+	return record.AutonomousSystemNumber
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// WithBlockBackend is used to serve blocked requests by a dedicated backend, e.g. a landing page
+// explaining the restriction, instead of responding with a static page. The country of a client
+// is passed to the backend in the geo headers unless they are disabled by WithInjectGeoHeader,
+// responses of the backend are returned as is.
+func WithBlockBackend(backendUrl string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if err := validateTarget(backendUrl); err != nil {
+			return nil, err
+		}
+
+		target, _ := url.Parse(backendUrl)
+		backend := proxy.newBlockedRequestsProxy("block backend", target)
+
+		proxy.blockResponse = "block backend"
+		proxy.blockBackendUrl = backendUrl
+		proxy.addAction(backend.ServeHTTP)
+		return proxy, nil
+	}
+}
+
+// newBlockedRequestsProxy returns a reverse proxy forwarding blocked requests to the target.
+// The original host and scheme of a request are passed in the X-Forwarded headers, the country
+// of a client is passed in the geo headers unless they are disabled by WithInjectGeoHeader.
+func (p *geoProxy) newBlockedRequestsProxy(name string, target *url.URL) *httputil.ReverseProxy {
+	reverseProxy := httputil.NewSingleHostReverseProxy(target)
+
+	director := reverseProxy.Director
+	reverseProxy.Director = func(req *http.Request) {
+		clientHost := req.Host
+		clientScheme := p.forwardedProto(req)
+
+		director(req)
+
+		req.Header.Set("X-Forwarded-Host", clientHost)
+		req.Header.Set("X-Forwarded-Proto", clientScheme)
+		req.Host = target.Host
+
+		if decision, ok := DecisionFromContext(req.Context()); ok && len(decision.Country) > 0 && p.injectGeoHeader {
+			p.setCountryHeaders(req.Header, decision.Country)
+		}
+	}
+	reverseProxy.ErrorHandler = func(res http.ResponseWriter, _ *http.Request, err error) {
+		p.requestLogger.Warn(name+" error",
+			zap.Error(err),
+		)
+		defaultAction(res, nil)
+	}
+
+	return reverseProxy
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBlockBackend(t *testing.T) {
+	var country string
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		country = req.Header.Get(geoHeaderName)
+		res.WriteHeader(http.StatusUnavailableForLegalReasons)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		inject   bool
+		expected string
+	}{
+		{true, "RU"},
+		{false, ""},
+	}
+
+	for _, test := range tests {
+		country = ""
+		p := openTestProxy(t,
+			WithResolver(countries(map[string]string{"2.2.2.2": "RU"})),
+			WithAllowedCountries([]string{"US"}),
+			WithBlockBackend(backend.URL),
+			WithInjectGeoHeader(test.inject),
+		)
+
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("2.2.2.2"))
+
+		if res.Code != http.StatusUnavailableForLegalReasons {
+			t.Errorf("expected a response of the block backend, got %d", res.Code)
+		}
+		if country != test.expected {
+			t.Errorf("inject %v: expected country header '%s', got '%s'", test.inject, test.expected, country)
+		}
+	}
+}
+
+func TestBlockBackendWithHoneypot(t *testing.T) {
+	_, err := New(0, "", "",
+		WithBlockBackend("http://blocked.example.com"),
+		WithHoneypotTarget("http://honeypot.example.com"),
+	)
+	if err == nil {
+		t.Error("block backend is combined with a honeypot target")
+	}
+}
+
+func TestHoneypot(t *testing.T) {
+	var marker, country string
+	honeypot := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		marker = req.Header.Get(honeypotHeaderName)
+		country = req.Header.Get(geoHeaderName)
+		_, _ = res.Write([]byte("honeypot"))
+	}))
+	defer honeypot.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if len(req.Header.Get(honeypotHeaderName)) > 0 {
+			t.Error("allowed request is marked as blocked")
+		}
+		_, _ = res.Write([]byte("target"))
+	}))
+	defer target.Close()
+
+	p, err := New(0, "", target.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+		WithHoneypotTarget(honeypot.URL),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("2.2.2.2"))
+	if body := res.Body.String(); res.Code != http.StatusOK || body != "honeypot" {
+		t.Errorf("blocked request does not reach the honeypot, got %d %s", res.Code, body)
+	}
+	if marker != "1" {
+		t.Errorf("request to the honeypot is not marked, got '%s'", marker)
+	}
+	if country != "RU" {
+		t.Errorf("expected country header RU in the request to the honeypot, got '%s'", country)
+	}
+
+	res = httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+	if body := res.Body.String(); res.Code != http.StatusOK || body != "target" {
+		t.Errorf("allowed request does not reach the target, got %d %s", res.Code, body)
+	}
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	blockPageFetchTimeout = 10 * time.Second
+	maxBlockPageSize      = 1 << 20
+)
+
+// remoteBlockPage is a block page fetched from a URL.
+type remoteBlockPage struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	lock        sync.RWMutex
+	content     []byte
+	contentType string
+
+	done chan struct{}
+}
+
+// WithRemoteBlockPage is used to configure a proxy to return a page fetched from an http(s) URL when
+// request is blocked. The page is fetched at startup, requests are blocked with 403 Forbidden
+// while it is not available.
+func WithRemoteBlockPage(pageUrl string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		u, err := url.Parse(pageUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, configError(ErrInvalidOption, "invalid block page URL '%s'", pageUrl)
+		}
+
+		page := &remoteBlockPage{
+			url:    pageUrl,
+			client: &http.Client{Timeout: blockPageFetchTimeout},
+		}
+		if proxy.blockPage != nil {
+			page.refresh = proxy.blockPage.refresh
+		}
+
+		proxy.blockPage = page
+		proxy.blockResponse = "remote page"
+		proxy.addAction(page.serve)
+		return proxy, nil
+	}
+}
+
+// WithBlockPageRefresh is used to periodically fetch a page configured with WithRemoteBlockPage.
+// The previously fetched page is kept when a refresh fails.
+func WithBlockPageRefresh(interval time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if interval <= 0 {
+			return nil, configError(ErrInvalidOption, "block page refresh interval must be positive")
+		}
+
+		if proxy.blockPage == nil {
+			proxy.blockPage = &remoteBlockPage{}
+		}
+		proxy.blockPage.refresh = interval
+		return proxy, nil
+	}
+}
+
+func (b *remoteBlockPage) fetch() error {
+	res, err := b.client.Get(b.url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	content, err := ioutil.ReadAll(http.MaxBytesReader(nil, res.Body, maxBlockPageSize))
+	if err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	b.content = content
+	b.contentType = res.Header.Get("Content-Type")
+	b.lock.Unlock()
+
+	return nil
+}
+
+func (b *remoteBlockPage) serve(res http.ResponseWriter, req *http.Request) {
+	b.lock.RLock()
+	content, contentType := b.content, b.contentType
+	b.lock.RUnlock()
+
+	if content == nil {
+		defaultAction(res, req)
+		return
+	}
+
+	if len(contentType) > 0 {
+		res.Header().Set("Content-Type", contentType)
+	}
+	_, _ = res.Write(content)
+}
+
+// startBlockPage fetches a remote block page and starts refreshing it when configured.
+func (p *geoProxy) startBlockPage() {
+	page := p.blockPage
+	if page == nil || len(page.url) == 0 {
+		return
+	}
+
+	if err := page.fetch(); err != nil {
+		p.logger.Warn("failed to fetch block page, falling back to the default response",
+			zap.String("url", page.url),
+			zap.Error(err),
+		)
+	}
+
+	if page.refresh <= 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	page.done = done
+	go func() {
+		ticker := p.clock.NewTicker(page.refresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				if err := page.fetch(); err != nil {
+					p.logger.Warn("failed to refresh block page",
+						zap.String("url", page.url),
+						zap.Error(err),
+					)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func (p *geoProxy) stopBlockPage() {
+	if p.blockPage != nil && p.blockPage.done != nil {
+		close(p.blockPage.done)
+		p.blockPage.done = nil
+	}
+}
+
+// WithNoContentBlock is used to respond to blocked requests with 204 No Content and no body,
+// e.g. for tracking beacons. It can not be combined with other block responses.
+func WithNoContentBlock() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.noContentBlock = true
+		proxy.addAction(func(res http.ResponseWriter, _ *http.Request) {
+			res.WriteHeader(http.StatusNoContent)
+		})
+		return proxy, nil
+	}
+}
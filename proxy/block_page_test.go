@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteBlockPage(t *testing.T) {
+	pages := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/blocked.html" {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		res.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = res.Write([]byte("<h1>Not available in your country</h1>"))
+	}))
+	defer pages.Close()
+
+	resolver := WithResolver(countries(map[string]string{"1.1.1.1": "RU"}))
+	blocked := WithBlockedCountries([]string{"RU"})
+
+	p := openTestProxy(t, resolver, blocked, WithRemoteBlockPage(pages.URL+"/blocked.html"))
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if res.Code != http.StatusOK {
+		t.Errorf("expected the page to be served with 200, got %d", res.Code)
+	}
+	if contentType := res.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+	if body := res.Body.String(); body != "<h1>Not available in your country</h1>" {
+		t.Errorf("unexpected page %s", body)
+	}
+
+	// the default response is used when the page can't be fetched
+	p = openTestProxy(t, resolver, blocked, WithRemoteBlockPage(pages.URL+"/missing.html"))
+	res = httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without a page, got %d", res.Code)
+	}
+}
+
+func TestRemoteBlockPageURL(t *testing.T) {
+	for _, pageUrl := range []string{"", "ftp://example.com/page", "/blocked.html", "http://"} {
+		if _, err := New(0, "", "", WithRemoteBlockPage(pageUrl)); err == nil {
+			t.Errorf("block page URL '%s' is accepted", pageUrl)
+		}
+	}
+}
+
+func TestRemoteBlockPageRefresh(t *testing.T) {
+	var version int32
+	pages := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(res, "page %d", atomic.AddInt32(&version, 1))
+	}))
+	defer pages.Close()
+
+	clock := newFakeClock()
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithRemoteBlockPage(pages.URL),
+		WithBlockPageRefresh(time.Minute),
+		WithClock(clock),
+	)
+
+	body := func() string {
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+		return res.Body.String()
+	}
+
+	if page := body(); page != "page 1" {
+		t.Fatalf("unexpected page %s", page)
+	}
+
+	clock.waitForTimers(t, 1)
+	clock.Advance(59 * time.Second)
+	if page := body(); page != "page 1" {
+		t.Errorf("page is refreshed before the interval: %s", page)
+	}
+
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for body() != "page 2" {
+		if time.Now().After(deadline) {
+			t.Fatalf("page is not refreshed, got %s", body())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNoContentBlock(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithNoContentBlock(),
+	)
+
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if res.Code != http.StatusNoContent {
+		t.Errorf("expected %d, got %d", http.StatusNoContent, res.Code)
+	}
+	if res.Body.Len() != 0 {
+		t.Errorf("expected an empty body, got %q", res.Body.String())
+	}
+}
+
+func TestNoContentBlockIsExclusive(t *testing.T) {
+	tests := map[string]StartOption{
+		"message":  WithMessage("blocked"),
+		"file":     WithFile("blocked.html"),
+		"redirect": WithRedirect("https://example.com/blocked"),
+	}
+
+	for name, opt := range tests {
+		if _, err := New(0, "", "", WithQuiet(), opt, WithNoContentBlock()); err == nil {
+			t.Errorf("no content block is combined with a %s block response", name)
+		}
+		if _, err := New(0, "", "", WithQuiet(), WithNoContentBlock(), opt); err == nil {
+			t.Errorf("%s block response is combined with a no content block", name)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"net"
+
+	"go.uber.org/zap"
+)
+
+// blockReason describes a rule which has blocked a request.
+type blockReason struct {
+	// rule is a type of the rule, e.g. country or network
+	rule string
+	// value is a value matched by the rule, e.g. a country code or a network
+	value string
+}
+
+func (p *geoProxy) logBlock(ip net.IP, reason *blockReason, fields ...zap.Field) {
+	fields = append([]zap.Field{
+		p.ipField(ip),
+		zap.String("rule", reason.rule),
+		zap.String("value", reason.value),
+	}, fields...)
+
+	p.requestLogger.Info("forbidden "+reason.rule, fields...)
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBlockReasonLogged(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"10.0.0.1": "US", "1.1.1.1": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithBlockedNetworks([]string{"10.0.0.0/8"}),
+	)
+	core, logs := observer.New(zapcore.InfoLevel)
+	p.requestLogger = zap.New(core)
+
+	tests := []struct {
+		addr    string
+		message string
+		rule    string
+		value   string
+	}{
+		{"10.0.0.1", "forbidden network", "network", "10.0.0.0/8"},
+		{"1.1.1.1", "forbidden country", "country", "RU"},
+	}
+
+	handler := p.Middleware()(okHandler)
+	for _, test := range tests {
+		handler.ServeHTTP(httptest.NewRecorder(), newTestRequest(test.addr))
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("%s: expected a single log entry, got %d", test.addr, len(entries))
+		}
+
+		entry := entries[0]
+		fields := entry.ContextMap()
+		if entry.Message != test.message || fields["rule"] != test.rule || fields["value"] != test.value {
+			t.Errorf("%s: expected '%s' with rule %s and value %s, got '%s' with %v",
+				test.addr, test.message, test.rule, test.value, entry.Message, fields)
+		}
+	}
+}
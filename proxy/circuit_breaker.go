@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops sending requests to a failing target for a while.
+type circuitBreaker struct {
+	threshold    int
+	resetTimeout time.Duration
+
+	lock     sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// breakerTransport reports results of requests to a circuit breaker.
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *circuitBreaker
+	clock   Clock
+}
+
+// WithCircuitBreaker is used to stop proxying requests to the target after failureThreshold consecutive
+// connection failures. Requests are rejected with 503 Service Unavailable for resetTimeout, after that
+// a single request is passed to the target to probe whether it has recovered.
+func WithCircuitBreaker(failureThreshold int, resetTimeout time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if failureThreshold <= 0 {
+			return nil, configError(ErrInvalidOption, "failure threshold must be positive")
+		}
+
+		if resetTimeout <= 0 {
+			return nil, configError(ErrInvalidOption, "reset timeout must be positive")
+		}
+
+		proxy.breaker = &circuitBreaker{
+			threshold:    failureThreshold,
+			resetTimeout: resetTimeout,
+		}
+		return proxy, nil
+	}
+}
+
+// allow reports whether a request can be passed to the target at the moment.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.resetTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *circuitBreaker) failure(now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+	b.probing = false
+}
+
+// cancel releases a probe whose request has been canceled by the client,
+// so the next request probes the target again.
+func (b *circuitBreaker) cancel() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == breakerHalfOpen && b.probing {
+		b.state = breakerOpen
+		b.probing = false
+	}
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		// a client has gone away, it says nothing about the target
+		if req.Context().Err() == nil {
+			t.breaker.failure(t.clock.Now())
+		} else {
+			t.breaker.cancel()
+		}
+		return nil, err
+	}
+
+	t.breaker.success()
+	return res, nil
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestCircuitBreakerCanceledProbe(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := &circuitBreaker{threshold: 1, resetTimeout: time.Minute}
+	transport := &breakerTransport{next: failingTransport{}, breaker: breaker, clock: realClock{}}
+
+	breaker.failure(start)
+	if breaker.allow(start.Add(time.Second)) {
+		t.Fatal("open breaker allows a request")
+	}
+
+	probeTime := start.Add(2 * time.Minute)
+	if !breaker.allow(probeTime) {
+		t.Fatal("breaker does not allow a probe after the reset timeout")
+	}
+	if breaker.allow(probeTime) {
+		t.Fatal("breaker allows a second probe")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("expected a transport error")
+	}
+
+	if breaker.probing {
+		t.Error("canceled probe is not released")
+	}
+	if breaker.state != breakerOpen {
+		t.Errorf("expected the breaker to return to open, got state %d", breaker.state)
+	}
+	if breaker.failures != 1 {
+		t.Errorf("canceled probe is counted as a failure, failures: %d", breaker.failures)
+	}
+	if !breaker.allow(probeTime) {
+		t.Error("breaker does not allow a new probe after a canceled one")
+	}
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// WithClientCertAuth is used to require TLS client certificates signed by the specified CAs, in addition to
+// filtering rules. TLS listeners reject connections without a valid certificate during the handshake,
+// requests which have not been authenticated with a certificate, e.g. over plain listeners,
+// are rejected with 403 Forbidden before they are filtered.
+func WithClientCertAuth(caPool *x509.CertPool) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if caPool == nil {
+			return nil, configError(ErrInvalidOption, "client CA pool is not specified")
+		}
+
+		proxy.clientCAs = caPool
+		return proxy, nil
+	}
+}
+
+// listenerTLSConfig returns a TLS config of a listener, requiring client certificates when configured.
+func (p *geoProxy) listenerTLSConfig(config *tls.Config) *tls.Config {
+	if p.clientCAs == nil {
+		return config
+	}
+
+	config = config.Clone()
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.ClientCAs = p.clientCAs
+	return config
+}
+
+// rejectUnauthenticated responds with 403 Forbidden when a request has no verified client certificate.
+func (p *geoProxy) rejectUnauthenticated(res http.ResponseWriter, req *http.Request) bool {
+	if p.clientCAs == nil || (req.TLS != nil && len(req.TLS.VerifiedChains) > 0) {
+		return false
+	}
+
+	p.requestLogger.Info("request without a client certificate",
+		p.addrField(req.RemoteAddr),
+	)
+	res.WriteHeader(http.StatusForbidden)
+	return true
+}
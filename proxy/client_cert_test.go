@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{cert: cert, key: key}
+}
+
+// clientCert issues a client certificate signed by the CA.
+func (ca *testCA) clientCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestClientCertAuth(t *testing.T) {
+	trusted := newTestCA(t, "trusted")
+	untrusted := newTestCA(t, "untrusted")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(trusted.cert)
+
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"127.0.0.1": "US"})),
+		WithAllowedCountries([]string{"US"}),
+		WithClientCertAuth(pool),
+	)
+
+	server := httptest.NewUnstartedServer(p.Middleware()(okHandler))
+	server.TLS = p.listenerTLSConfig(&tls.Config{})
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		config := &tls.Config{InsecureSkipVerify: true}
+		// the certificate is sent even when it is not signed by a CA accepted by the server
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if len(certs) == 0 {
+				return &tls.Certificate{}, nil
+			}
+			return &certs[0], nil
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		return client.Get(server.URL)
+	}
+
+	res, err := get(trusted.clientCert(t))
+	if err != nil {
+		t.Fatalf("request with a valid certificate has failed: %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for a valid certificate, got %d", res.StatusCode)
+	}
+
+	if res, err := get(untrusted.clientCert(t)); err == nil {
+		_ = res.Body.Close()
+		t.Errorf("request with an untrusted certificate is answered with %d", res.StatusCode)
+	}
+
+	if res, err := get(); err == nil {
+		_ = res.Body.Close()
+		t.Errorf("request without a certificate is answered with %d", res.StatusCode)
+	}
+
+	// plain listeners have no certificates to verify
+	plain := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(plain, newTestRequest("127.0.0.1"))
+	if plain.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a request without TLS, got %d", plain.Code)
+	}
+	if stats := p.Stats(); stats.Allowed != 1 {
+		t.Errorf("expected only the authenticated request to be filtered, got %+v", stats)
+	}
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only changes when it is advanced by a test, timers fire when their time comes.
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a timer or a ticker of a fake clock, tickers have a period.
+type fakeTimer struct {
+	clock  *fakeClock
+	when   time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	timer := &fakeTimer{clock: c, when: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		timer.c <- c.now
+		return timer
+	}
+
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ticker := &fakeTimer{clock: c, when: c.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, ticker)
+	return fakeTicker{ticker}
+}
+
+// Set changes the time without firing timers.
+func (c *fakeClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = now
+}
+
+// Advance moves the time forward and fires the timers which are due.
+// Like real tickers, fake tickers drop ticks when a receiver falls behind.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.when.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+
+		select {
+		case timer.c <- c.now:
+		default:
+		}
+
+		if timer.period > 0 {
+			for !timer.when.After(c.now) {
+				timer.when = timer.when.Add(timer.period)
+			}
+			pending = append(pending, timer)
+		}
+	}
+	c.timers = pending
+}
+
+// waitForTimers waits until the number of pending timers is n.
+func (c *fakeClock) waitForTimers(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.pendingTimers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d pending timers, got %d", n, c.pendingTimers())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *fakeClock) pendingTimers() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.timers)
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.remove(t)
+}
+
+func (c *fakeClock) remove(t *fakeTimer) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.clock.remove(t.fakeTimer)
+}
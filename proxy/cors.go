@@ -0,0 +1,46 @@
+package proxy
+
+import "net/http"
+
+// WithBlockedPreflightCORS is used to add CORS headers to responses to blocked preflight requests,
+// so browsers report the block status instead of a CORS error. Origins are matched exactly,
+// "*" allows any origin.
+func WithBlockedPreflightCORS(origins []string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(origins) == 0 {
+			return nil, configError(ErrInvalidOption, "CORS origins are not specified")
+		}
+
+		proxy.corsOrigins = make(map[string]bool)
+		for _, origin := range origins {
+			proxy.corsOrigins[origin] = true
+		}
+		return proxy, nil
+	}
+}
+
+func isPreflightRequest(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// setPreflightCORS adds CORS headers to a response to a blocked preflight request.
+func (p *geoProxy) setPreflightCORS(res http.ResponseWriter, req *http.Request) {
+	if len(p.corsOrigins) == 0 || !isPreflightRequest(req) {
+		return
+	}
+
+	origin := req.Header.Get("Origin")
+	if !p.corsOrigins[origin] && !p.corsOrigins["*"] {
+		return
+	}
+
+	header := res.Header()
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Set("Access-Control-Allow-Methods", req.Header.Get("Access-Control-Request-Method"))
+	if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		header.Set("Access-Control-Allow-Headers", headers)
+	}
+	header.Add("Vary", "Origin")
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBlockedPreflightCORS(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithBlockedPreflightCORS([]string{"https://example.com"}),
+	)
+	handler := p.Middleware()(okHandler)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := newTestRequest("1.1.1.1")
+		req.Method = http.MethodOptions
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	res := preflight("https://example.com")
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, res.Code)
+	}
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "https://example.com",
+		"Access-Control-Allow-Methods": http.MethodPost,
+		"Access-Control-Allow-Headers": "Content-Type",
+		"Vary":                         "Origin",
+	}
+	for name, value := range expected {
+		if got := res.Header().Get(name); got != value {
+			t.Errorf("expected %s header '%s', got '%s'", name, value, got)
+		}
+	}
+
+	res = preflight("https://other.com")
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, res.Code)
+	}
+	if origin := res.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("expected no CORS headers for an unknown origin, got '%s'", origin)
+	}
+
+	// blocked requests which are not preflights get no CORS headers
+	req := newTestRequest("1.1.1.1")
+	req.Header.Set("Origin", "https://example.com")
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	if origin := res.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("expected no CORS headers for a GET request, got '%s'", origin)
+	}
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	countrycodes "github.com/biter777/countries"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// CountrySource defines which country of a GeoIP record is used for filtering.
+type CountrySource int
+
+const (
+	// SourceCountry is the country where a client is located. It is used by default.
+	SourceCountry CountrySource = iota
+	// SourceRegisteredCountry is the country where an ISP has registered the network.
+	SourceRegisteredCountry
+	// SourceRepresentedCountry is the country represented by users of the IP address, e.g. a military base.
+	SourceRepresentedCountry
+)
+
+// EUMembers are alpha-2 codes of the European Union member states. The countries package has no notion
+// of the European Union, so the list is maintained by hand: keep it in sync with the membership,
+// e.g. GB is not a member since 2020.
+var EUMembers = []string{
+	"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+	"IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO", "SE", "SI", "SK",
+}
+
+func isEUMember(isoCode string) bool {
+	for _, code := range EUMembers {
+		if code == isoCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+type countryInfo struct {
+	isoCode string
+	inEU    bool
+	names   map[string]string
+}
+
+// WithCountrySource is used to configure which country of a GeoIP record is used for filtering.
+func WithCountrySource(source CountrySource) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		switch source {
+		case SourceCountry, SourceRegisteredCountry, SourceRepresentedCountry:
+			proxy.countrySource = source
+		default:
+			return nil, configError(ErrInvalidOption, "unknown country source %d", source)
+		}
+
+		return proxy, nil
+	}
+}
+
+// WithCountryMapper is used to transform resolved country codes before they are filtered and passed to the target,
+// e.g. to treat Kosovo (XK) as Serbia (RS). The mapper receives an ISO code, which is empty for unknown countries.
+// EU membership and the English name of a mapped country are derived from the returned code, other names are dropped.
+func WithCountryMapper(mapper func(string) string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if mapper == nil {
+			return nil, configError(ErrInvalidOption, "country mapper is not specified")
+		}
+
+		proxy.countryMapper = mapper
+		return proxy, nil
+	}
+}
+
+// selectCountry returns the country of a record used for filtering, transformed by the country mapper.
+func (p *geoProxy) selectCountry(record *geoip2.Country) countryInfo {
+	info := selectCountry(record, p.countrySource)
+	if p.countryMapper != nil {
+		if isoCode := p.countryMapper(info.isoCode); isoCode != info.isoCode {
+			info = mappedCountry(isoCode)
+		}
+	}
+
+	return info
+}
+
+// mappedCountry returns a country for a code returned by the country mapper.
+func mappedCountry(isoCode string) countryInfo {
+	info := countryInfo{isoCode: isoCode, inEU: isEUMember(isoCode)}
+	if code := countrycodes.ByName(isoCode); code.Alpha2() == isoCode {
+		info.names = map[string]string{"en": code.String()}
+	}
+
+	return info
+}
+
+func selectCountry(record *geoip2.Country, source CountrySource) countryInfo {
+	switch source {
+	case SourceRegisteredCountry:
+		c := record.RegisteredCountry
+		return countryInfo{isoCode: c.IsoCode, inEU: c.IsInEuropeanUnion, names: c.Names}
+	case SourceRepresentedCountry:
+		c := record.RepresentedCountry
+		return countryInfo{isoCode: c.IsoCode, inEU: c.IsInEuropeanUnion, names: c.Names}
+	default:
+		c := record.Country
+		return countryInfo{isoCode: c.IsoCode, inEU: c.IsInEuropeanUnion, names: c.Names}
+	}
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+func TestCountryMapper(t *testing.T) {
+	mapper := func(isoCode string) string {
+		switch isoCode {
+		case "XK":
+			return "RS"
+		case "CH":
+			return "AT"
+		}
+		return isoCode
+	}
+	p := openTestProxy(t, WithResolver(countries(nil)), WithCountryMapper(mapper))
+
+	kosovo := &geoip2.Country{}
+	kosovo.Country.IsoCode = "XK"
+	kosovo.Country.Names = map[string]string{"en": "Kosovo", "de": "Kosovo"}
+
+	info := p.selectCountry(kosovo)
+	if info.isoCode != "RS" || info.inEU || info.names["en"] != "Serbia" || len(info.names) != 1 {
+		t.Errorf("unexpected mapped country %+v", info)
+	}
+
+	switzerland := &geoip2.Country{}
+	switzerland.Country.IsoCode = "CH"
+	if info := p.selectCountry(switzerland); info.isoCode != "AT" || !info.inEU {
+		t.Errorf("EU membership is not derived from the mapped country %+v", info)
+	}
+
+	germany := &geoip2.Country{}
+	germany.Country.IsoCode = "DE"
+	germany.Country.IsInEuropeanUnion = true
+	germany.Country.Names = map[string]string{"en": "Germany", "fr": "Allemagne"}
+	if info := p.selectCountry(germany); info.isoCode != "DE" || !info.inEU || len(info.names) != 2 {
+		t.Errorf("unmapped country is changed %+v", info)
+	}
+}
+
+func TestCountryMapperRules(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "CH"})),
+		WithCountryMapper(func(string) string { return "AT" }),
+		WithAllowEUOnly(),
+	)
+
+	var header string
+	handler := p.Middleware()(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		header = req.Header.Get(geoHeaderName)
+	}))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("mapped EU country is blocked with %d", res.Code)
+	}
+	if header != "AT" {
+		t.Errorf("geo header %s doesn't match the mapped country", header)
+	}
+}
+
+func TestEUMembers(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, code := range EUMembers {
+		if seen[code] {
+			t.Errorf("duplicate EU member %s", code)
+		}
+		seen[code] = true
+	}
+
+	if len(seen) != 27 {
+		t.Errorf("expected 27 EU member states, got %d", len(seen))
+	}
+	for _, code := range []string{"GB", "CH", "NO"} {
+		if isEUMember(code) {
+			t.Errorf("%s is not an EU member state", code)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"net"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+	"go.uber.org/zap"
+)
+
+// WithIPv6Database is used to resolve IPv6 addresses with a separate database.
+// The main database is used for IPv4 addresses only.
+func WithIPv6Database(path string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(path) == 0 {
+			return nil, configError(ErrDatabase, "IPv6 database path is not specified")
+		}
+
+		proxy.ipv6DbPath = path
+		return proxy, nil
+	}
+}
+
+const dbWaitPollInterval = time.Second
+
+// WithWaitForDatabase is used to wait at startup until databases can be loaded, e.g. when they are
+// downloaded by another container. Loading is retried every second until the timeout passes.
+func WithWaitForDatabase(timeout time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if timeout <= 0 {
+			return nil, configError(ErrInvalidOption, "database wait timeout must be positive")
+		}
+
+		proxy.dbWaitTimeout = timeout
+		return proxy, nil
+	}
+}
+
+// dbPaths returns paths of all configured databases.
+func (p *geoProxy) dbPaths() []string {
+	p.dbLock.RLock()
+	defer p.dbLock.RUnlock()
+
+	paths := []string{p.dbPath}
+	if len(p.ipv6DbPath) > 0 {
+		paths = append(paths, p.ipv6DbPath)
+	}
+
+	return paths
+}
+
+// dbFor returns a database to resolve an address of the IP family.
+func (p *geoProxy) dbFor(ip net.IP) *geoip2.Reader {
+	if p.ipv6Db != nil && ip.To4() == nil {
+		return p.ipv6Db
+	}
+
+	return p.db
+}
+
+func (p *geoProxy) loadDatabases() (db *geoip2.Reader, ipv6Db *geoip2.Reader, err error) {
+	paths := p.dbPaths()
+
+	db, err = loadGeoDb(paths[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(paths) > 1 {
+		ipv6Db, err = loadGeoDb(paths[1])
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, err
+		}
+	}
+
+	return db, ipv6Db, nil
+}
+
+// waitForDatabases loads databases, retrying until the timeout set by WithWaitForDatabase passes.
+func (p *geoProxy) waitForDatabases() (db *geoip2.Reader, ipv6Db *geoip2.Reader, err error) {
+	deadline := p.clock.Now().Add(p.dbWaitTimeout)
+	waiting := false
+
+	for {
+		db, ipv6Db, err = p.loadDatabases()
+		if err == nil || !p.clock.Now().Before(deadline) {
+			return db, ipv6Db, err
+		}
+
+		if !waiting {
+			p.logger.Info("waiting for Geo DB",
+				zap.Duration("timeout", p.dbWaitTimeout),
+				zap.Error(err),
+			)
+			waiting = true
+		}
+
+		<-p.clock.After(dbWaitPollInterval)
+	}
+}
+
+func (p *geoProxy) closeDatabases() error {
+	if p.db == nil {
+		return nil
+	}
+
+	err := p.db.Close()
+	if p.ipv6Db != nil {
+		if ipv6Err := p.ipv6Db.Close(); err == nil {
+			err = ipv6Err
+		}
+	}
+
+	return err
+}
+
+// WithResolver is used to resolve countries with a custom function instead of GeoIP databases,
+// e.g. with a fake resolver in tests. Databases are not loaded when the option is specified.
+func WithResolver(resolve func(ip net.IP) (*geoip2.Country, error)) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if resolve == nil {
+			return nil, configError(ErrInvalidOption, "resolver is not specified")
+		}
+
+		proxy.resolve = resolve
+		proxy.customResolver = true
+		return proxy, nil
+	}
+}
+
+// WithDatabaseSwap is used to allow replacing a GeoIP database of a running proxy with SwapDatabase.
+func WithDatabaseSwap() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.enableDbLock()
+		return proxy, nil
+	}
+}
+
+// enableDbLock makes lookups safe against replacing databases at runtime.
+func (p *geoProxy) enableDbLock() {
+	if !p.customResolver {
+		p.resolve = p.resolveIpWithLock
+	}
+	p.dbLocked = true
+}
+
+// SwapDatabase atomically replaces the main GeoIP database with a database loaded from another path,
+// e.g. to roll out a new database without a restart. The proxy must be created with WithDatabaseSwap,
+// WithAutoReload or WithAdminListener. When the database is watched, the new directory is watched as well.
+func (p *geoProxy) SwapDatabase(path string) error {
+	if !p.dbLocked {
+		return configError(ErrDatabase, "database swap is not enabled")
+	}
+
+	return p.reloads.exclusive(func() error {
+		return p.swapDatabase(path)
+	})
+}
+
+func (p *geoProxy) swapDatabase(path string) error {
+	db, err := loadGeoDb(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Country(net.IPv4(8, 8, 8, 8)); err != nil {
+		if _, ok := err.(geoip2.InvalidMethodError); ok {
+			_ = db.Close()
+			return configError(ErrDatabase, "'%s' is not a country database", path)
+		}
+	}
+
+	p.dbLock.Lock()
+	oldDb := p.db
+	p.db, p.dbPath = db, path
+	p.dbLock.Unlock()
+
+	p.afterDbReload()
+
+	if p.watcher != nil {
+		for _, dir := range p.dbWatchDirs() {
+			if err := p.watcher.Add(dir); err != nil {
+				p.logger.Warn("failed to watch database directory",
+					zap.String("dir", dir),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+
+	p.logger.Info("Geo DB is swapped",
+		zap.String("db", path),
+	)
+
+	return oldDb.Close()
+}
+
+// afterDbReload refreshes state derived from replaced databases.
+func (p *geoProxy) afterDbReload() {
+	if p.cache != nil {
+		p.cache.clear()
+		p.warmUpCache()
+	}
+}
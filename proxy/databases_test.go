@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	stderrors "errors"
+	"geofilter/internal/mmdbtest"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestDatabase writes a database with the records to a temporary directory and returns its path.
+func writeTestDatabase(t *testing.T, dbType string, records map[string]interface{}) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	path := filepath.Join(dir, "geo.mmdb")
+	if err := mmdbtest.Write(path, dbType, records); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestIPv6Database(t *testing.T) {
+	db := writeTestDatabase(t, "GeoLite2-Country", map[string]interface{}{
+		"1.1.1.0/24":    mmdbtest.Country("US"),
+		"2001:db8::/32": mmdbtest.Country("FR"),
+	})
+	ipv6Db := writeTestDatabase(t, "GeoLite2-Country", map[string]interface{}{
+		"1.1.1.0/24":    mmdbtest.Country("RU"),
+		"2001:db8::/32": mmdbtest.Country("DE"),
+	})
+
+	p, err := New(0, db, "", WithQuiet(), WithIPv6Database(ipv6Db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	expected := map[string]string{
+		"1.1.1.1":        "US",
+		"::ffff:1.1.1.1": "US",
+		"2001:db8::1":    "DE",
+	}
+
+	check := func() {
+		for addr, code := range expected {
+			country, err := p.resolve(net.ParseIP(addr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if country.Country.IsoCode != code {
+				t.Errorf("%s is resolved as %s, expected %s", addr, country.Country.IsoCode, code)
+			}
+		}
+	}
+
+	check()
+
+	if err := p.reloadGeoDb(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
+
+func TestIPv6DatabaseMissing(t *testing.T) {
+	db := writeTestDatabase(t, "GeoLite2-Country", map[string]interface{}{"1.1.1.0/24": mmdbtest.Country("US")})
+
+	p, err := New(0, db, "", WithQuiet(), WithIPv6Database(filepath.Join(filepath.Dir(db), "missing.mmdb")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Open(); err == nil {
+		t.Error("proxy is opened without the IPv6 database")
+	}
+}
+
+func TestEnterpriseDatabase(t *testing.T) {
+	record := func(isoCode, city string, asn uint32) map[string]interface{} {
+		return map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": isoCode},
+			"city":    map[string]interface{}{"names": map[string]interface{}{"en": city}},
+			"traits":  map[string]interface{}{"autonomous_system_number": asn},
+		}
+	}
+	db := writeTestDatabase(t, "GeoIP2-Enterprise", map[string]interface{}{
+		"1.1.1.0/24": record("US", "Boston", 64500),
+		"2.2.2.0/24": record("FR", "Paris", 64501),
+	})
+
+	// no separate ASN database is required
+	p, err := New(0, db, "", WithQuiet(), WithBlockedASNs([]uint{64501}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tests := []struct {
+		addr    string
+		country string
+		city    string
+		asn     uint
+		status  int
+	}{
+		{"1.1.1.1", "US", "Boston", 64500, http.StatusOK},
+		{"2.2.2.2", "FR", "Paris", 64501, http.StatusForbidden},
+	}
+
+	handler := p.Middleware()(okHandler)
+	for _, test := range tests {
+		ip := net.ParseIP(test.addr)
+
+		country, err := p.resolve(ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if country.Country.IsoCode != test.country {
+			t.Errorf("%s is resolved as %s, expected %s", test.addr, country.Country.IsoCode, test.country)
+		}
+		if city := p.lookupCity(ip); city != test.city {
+			t.Errorf("city of %s is '%s', expected '%s'", test.addr, city, test.city)
+		}
+		if asn := p.lookupASN(ip); asn != test.asn {
+			t.Errorf("ASN of %s is %d, expected %d", test.addr, asn, test.asn)
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest(test.addr))
+		if res.Code != test.status {
+			t.Errorf("%s: expected %d, got %d", test.addr, test.status, res.Code)
+		}
+	}
+}
+
+func TestWaitForDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "geo.mmdb")
+
+	clock := newFakeClock()
+	p, err := New(0, path, "", WithQuiet(), WithClock(clock), WithWaitForDatabase(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened := make(chan error, 1)
+	go func() {
+		opened <- p.Open()
+	}()
+
+	// the database is downloaded while the proxy is polling for it
+	clock.waitForTimers(t, 1)
+	clock.Advance(dbWaitPollInterval)
+	clock.waitForTimers(t, 1)
+	if err := mmdbtest.Write(path, "GeoLite2-Country", map[string]interface{}{"1.1.1.0/24": mmdbtest.Country("US")}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(dbWaitPollInterval)
+
+	select {
+	case err := <-opened:
+		if err != nil {
+			t.Fatalf("proxy is not opened after the database has appeared: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy is still waiting for the database")
+	}
+	defer p.Close()
+
+	country, err := p.resolve(net.ParseIP("1.1.1.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if country.Country.IsoCode != "US" {
+		t.Errorf("expected US, got %s", country.Country.IsoCode)
+	}
+}
+
+func TestWaitForDatabaseTimeout(t *testing.T) {
+	clock := newFakeClock()
+	p, err := New(0, "/nonexistent/geo.mmdb", "", WithQuiet(), WithClock(clock), WithWaitForDatabase(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened := make(chan error, 1)
+	go func() {
+		opened <- p.Open()
+	}()
+
+	for i := 0; i < 60; i++ {
+		clock.waitForTimers(t, 1)
+		clock.Advance(dbWaitPollInterval)
+	}
+
+	select {
+	case err := <-opened:
+		if !stderrors.Is(err, ErrDatabase) {
+			_ = p.Close()
+			t.Errorf("expected %v after the timeout, got %v", ErrDatabase, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy is still waiting for the database after the timeout")
+	}
+}
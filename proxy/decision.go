@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+)
+
+type decisionContextKey struct{}
+
+// Decision describes how a proxy has handled a request.
+type Decision struct {
+	// Allowed is true when the request is passed to the target.
+	Allowed bool
+	// Country is an ISO code of a client's country, it is empty when the country is not resolved.
+	Country string
+}
+
+type decisionHolder struct {
+	decision Decision
+	recorded bool
+}
+
+// DecisionFromContext returns a decision stored in the request context by a proxy.
+// It is available to the handlers invoked after the request has been filtered.
+func DecisionFromContext(ctx context.Context) (Decision, bool) {
+	holder, ok := ctx.Value(decisionContextKey{}).(*decisionHolder)
+	if !ok || !holder.recorded {
+		return Decision{}, false
+	}
+
+	return holder.decision, true
+}
+
+// WithDecisionRecorder prepares a request context to make a decision visible to the caller
+// which passes the request to a filtering handler, including decisions on blocked requests.
+func WithDecisionRecorder(req *http.Request) *http.Request {
+	ctx := context.WithValue(req.Context(), decisionContextKey{}, &decisionHolder{})
+	return req.WithContext(ctx)
+}
+
+func withDecision(req *http.Request, decision Decision) *http.Request {
+	if holder, ok := req.Context().Value(decisionContextKey{}).(*decisionHolder); ok {
+		holder.decision = decision
+		holder.recorded = true
+		return req
+	}
+
+	holder := &decisionHolder{decision: decision, recorded: true}
+	ctx := context.WithValue(req.Context(), decisionContextKey{}, holder)
+	return req.WithContext(ctx)
+}
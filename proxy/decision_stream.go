@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// decisionStreamBuffer is a number of events buffered for each subscriber,
+// events are dropped for subscribers which do not keep up.
+const decisionStreamBuffer = 64
+
+type decisionEvent struct {
+	Time    time.Time `json:"time"`
+	IP      string    `json:"ip"`
+	Country string    `json:"country,omitempty"`
+	Action  string    `json:"action"`
+}
+
+// WithDecisionStream is used to stream decisions on requests as server-sent events on the specified path
+// for live debugging. The endpoint is served only by the admin server, so WithAdminListener is required.
+// Client addresses are anonymized when WithIPAnonymization is used. Streams are closed by the server write timeout, event source clients reconnect automatically.
+func WithDecisionStream(path string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		path = strings.TrimSpace(path)
+		if !strings.HasPrefix(path, "/") {
+			return nil, configError(ErrInvalidOption, "invalid decision stream path '%s', expected an absolute path", path)
+		}
+
+		proxy.decisionStreamPath = path
+		proxy.decisionStream = newDecisionBroadcast()
+		return proxy, nil
+	}
+}
+
+type decisionBroadcast struct {
+	lock        sync.Mutex
+	subscribers map[chan decisionEvent]struct{}
+}
+
+func newDecisionBroadcast() *decisionBroadcast {
+	return &decisionBroadcast{subscribers: make(map[chan decisionEvent]struct{})}
+}
+
+func (b *decisionBroadcast) subscribe() chan decisionEvent {
+	events := make(chan decisionEvent, decisionStreamBuffer)
+
+	b.lock.Lock()
+	b.subscribers[events] = struct{}{}
+	b.lock.Unlock()
+
+	return events
+}
+
+func (b *decisionBroadcast) unsubscribe(events chan decisionEvent) {
+	b.lock.Lock()
+	delete(b.subscribers, events)
+	b.lock.Unlock()
+}
+
+func (b *decisionBroadcast) publish(event decisionEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// publishDecision sends a decision on a filtered request to subscribers of the decision stream.
+func (p *geoProxy) publishDecision(req *http.Request) {
+	decision, ok := DecisionFromContext(req.Context())
+	if !ok {
+		return
+	}
+
+	action := "blocked"
+	if decision.Allowed {
+		action = "allowed"
+	}
+
+	event := decisionEvent{
+		Time:    p.clock.Now(),
+		Country: decision.Country,
+		Action:  action,
+	}
+	if ip := getIP(p.clientAddr(req)); ip != nil {
+		if p.anonymizeIP {
+			ip = anonymizeIP(ip)
+		}
+		event.IP = ip.String()
+	}
+
+	p.decisionStream.publish(event)
+}
+
+func (p *geoProxy) decisionStreamHandler(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := p.decisionStream.subscribe()
+	defer p.decisionStream.unsubscribe(events)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			if _, err := fmt.Fprintf(res, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecisionStream(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+		WithAdminListener("127.0.0.1:0"),
+		WithDecisionStream("/decisions"),
+		WithIPAnonymization(),
+	)
+
+	admin := p.setupAdminEndpoints()
+	server := httptest.NewServer(admin.Handler)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/decisions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if contentType := res.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("unexpected content type '%s'", contentType)
+	}
+
+	handler := p.Middleware()(okHandler)
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("1.1.1.1"))
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("2.2.2.2"))
+
+	expected := []decisionEvent{
+		{IP: "1.1.1.0", Country: "US", Action: "allowed"},
+		{IP: "2.2.2.0", Country: "RU", Action: "blocked"},
+	}
+
+	reader := bufio.NewReader(res.Body)
+	for _, want := range expected {
+		var line string
+		for !strings.HasPrefix(line, "data: ") {
+			if line, err = reader.ReadString('\n'); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var event decisionEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatal(err)
+		}
+		event.Time = want.Time
+
+		if event != want {
+			t.Errorf("expected %+v, got %+v", want, event)
+		}
+	}
+}
+
+func TestDecisionStreamOnlyOnAdminListener(t *testing.T) {
+	if _, err := New(0, "", "", WithDecisionStream("/decisions")); err == nil {
+		t.Error("decision stream is accepted without an admin listener")
+	}
+
+	p := openTestProxy(t,
+		WithResolver(countries(nil)),
+		WithAdminListener("127.0.0.1:0"),
+		WithDecisionStream("/decisions"),
+	)
+	p.setupAdminEndpoints()
+	p.setupPublicEndpoints()
+
+	req := httptest.NewRequest(http.MethodGet, "/decisions", nil)
+	if _, pattern := p.mux.Handler(req); pattern == "/decisions" {
+		t.Error("decision stream is served on the public port")
+	}
+}
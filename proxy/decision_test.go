@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecisionFromContext(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+	)
+
+	var inner Decision
+	next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		inner, _ = DecisionFromContext(req.Context())
+		res.WriteHeader(http.StatusOK)
+	})
+	filter := p.Middleware()(next)
+
+	var outer Decision
+	var recorded bool
+	wrapping := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req = WithDecisionRecorder(req)
+		filter.ServeHTTP(res, req)
+		outer, recorded = DecisionFromContext(req.Context())
+	})
+
+	tests := []struct {
+		addr     string
+		expected Decision
+	}{
+		{"1.1.1.1", Decision{Allowed: true, Country: "US"}},
+		{"2.2.2.2", Decision{Allowed: false, Country: "RU"}},
+	}
+
+	for _, test := range tests {
+		outer, recorded = Decision{}, false
+		wrapping.ServeHTTP(httptest.NewRecorder(), newTestRequest(test.addr))
+
+		if !recorded {
+			t.Fatalf("%s: decision is not recorded", test.addr)
+		}
+		if outer != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.addr, test.expected, outer)
+		}
+	}
+
+	if inner != (Decision{Allowed: true, Country: "US"}) {
+		t.Errorf("handler behind the filter got %+v", inner)
+	}
+}
+
+func TestDecisionFromContextWithoutRecorder(t *testing.T) {
+	req := newTestRequest("1.1.1.1")
+	if _, ok := DecisionFromContext(req.Context()); ok {
+		t.Error("decision is reported for a request that has not been filtered")
+	}
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strings"
+)
+
+// Configuration errors returned by New, options and runtime configuration methods.
+// They are wrapped with details and can be checked with errors.Is.
+var (
+	// ErrUnknownCountry is returned for country codes which are not alpha-2 codes.
+	ErrUnknownCountry = stderrors.New("unknown country")
+	// ErrInvalidTarget is returned for target URLs which can not be proxied to.
+	ErrInvalidTarget = stderrors.New("invalid target")
+	// ErrNoRule is returned when an option does not define a filtering rule.
+	ErrNoRule = stderrors.New("no filtering rule")
+	// ErrDatabase is returned when a GeoIP database can not be loaded or has an unsupported type.
+	ErrDatabase = stderrors.New("database error")
+	// ErrInvalidOption is returned for other invalid option values and combinations of options.
+	ErrInvalidOption = stderrors.New("invalid option")
+)
+
+// configError returns a configuration error of the kind with details.
+func configError(kind error, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", kind, fmt.Sprintf(format, args...))
+}
+
+// normalizeCountries converts country codes to upper case and checks they look like alpha-2 codes.
+func normalizeCountries(countries []string) ([]string, error) {
+	normalized := make([]string, 0, len(countries))
+	for _, c := range countries {
+		code := strings.ToUpper(strings.TrimSpace(c))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, configError(ErrUnknownCountry, "'%s' is not an alpha-2 code", c)
+		}
+		normalized = append(normalized, code)
+	}
+
+	return normalized, nil
+}
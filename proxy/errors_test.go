@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	stderrors "errors"
+	"testing"
+)
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		opt    StartOption
+		err    error
+	}{
+		{"no allowed countries", "", WithAllowedCountries(nil), ErrNoRule},
+		{"no blocked ASNs", "", WithBlockedASNs(nil), ErrNoRule},
+		{"no allowed networks", "", WithAllowedNetworks(nil), ErrNoRule},
+		{"unknown country", "", WithBlockedCountries([]string{"USA"}), ErrUnknownCountry},
+		{"invalid target", "localhost:8081", WithQuiet(), ErrInvalidTarget},
+		{"invalid weighted targets", "", WithWeightedTargets(map[string]int{"http://localhost:8081": -1}), ErrInvalidTarget},
+		{"no transport", "", WithTransport(nil), ErrInvalidOption},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(0, "", test.target, test.opt)
+			if !stderrors.Is(err, test.err) {
+				t.Errorf("New() error = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestDatabaseError(t *testing.T) {
+	p, err := New(0, "/nonexistent/missing.mmdb", "", WithQuiet())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Open(); !stderrors.Is(err, ErrDatabase) {
+		_ = p.Close()
+		t.Errorf("Open() error = %v, want %v", err, ErrDatabase)
+	}
+}
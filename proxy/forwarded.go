@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+)
+
+// splitQuoted splits s by sep, ignoring separators inside quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes, escaped := false, false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unquote removes quotes and escapes of a quoted string.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// forwardedNodeIP parses a node of a Forwarded header like 192.0.2.43, 192.0.2.43:47011
+// or [2001:db8:cafe::17]:4711. Obfuscated identifiers like _hidden and "unknown" return nil.
+func forwardedNodeIP(node string) net.IP {
+	if strings.HasPrefix(node, "[") {
+		end := strings.Index(node, "]")
+		if end < 0 {
+			return nil
+		}
+		return net.ParseIP(node[1:end])
+	}
+
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+
+	return net.ParseIP(node)
+}
+
+// parseForwarded returns the first valid client address of a Forwarded header defined by RFC 7239,
+// e.g. for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711".
+func parseForwarded(header string) net.IP {
+	for _, element := range splitQuoted(header, ',') {
+		for _, pair := range splitQuoted(element, ';') {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+				continue
+			}
+
+			if ip := forwardedNodeIP(unquote(strings.TrimSpace(kv[1]))); ip != nil {
+				return ip
+			}
+		}
+	}
+
+	return nil
+}
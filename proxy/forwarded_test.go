@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseForwarded(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		// examples of RFC 7239
+		{`for="_gazonk"`, ""},
+		{`For="[2001:db8:cafe::17]:4711"`, "2001:db8:cafe::17"},
+		{`for=192.0.2.60;proto=http;by=203.0.113.43`, "192.0.2.60"},
+		{`for=192.0.2.43, for=198.51.100.17`, "192.0.2.43"},
+		{`for=192.0.2.43,for="[2001:db8:cafe::17]",for=unknown`, "192.0.2.43"},
+		{`for=unknown, for=192.0.2.43`, "192.0.2.43"},
+		{`for=_hidden, for=_SEVKISEK`, ""},
+		{`for="192.0.2.43:47011"`, "192.0.2.43"},
+		{`for="[2001:db8:cafe::17]"`, "2001:db8:cafe::17"},
+		// quoted separators and escapes
+		{`host="a,b;c";for=192.0.2.1`, "192.0.2.1"},
+		{`host="a\"b, for=10.0.0.1";for=192.0.2.2`, "192.0.2.2"},
+		// malformed nodes
+		{`for="[2001:db8:cafe::17"`, ""},
+		{`for=`, ""},
+		{`by=203.0.113.43`, ""},
+		{``, ""},
+	}
+
+	for _, test := range tests {
+		ip := parseForwarded(test.header)
+		if test.expected == "" {
+			if ip != nil {
+				t.Errorf("%s: expected no address, got %s", test.header, ip)
+			}
+			continue
+		}
+
+		if !ip.Equal(net.ParseIP(test.expected)) {
+			t.Errorf("%s: expected %s, got %s", test.header, test.expected, ip)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+type geoJSON struct {
+	Country   string `json:"country"`
+	Continent string `json:"continent"`
+	City      string `json:"city,omitempty"`
+}
+
+// WithGeoJSONHeader is used to pass geo data of a client to the target as a base64-encoded JSON
+// in the specified request header. The city is included when a city database is used.
+func WithGeoJSONHeader(name string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(strings.TrimSpace(name)) == 0 {
+			return nil, configError(ErrInvalidOption, "geo JSON header name is not specified")
+		}
+
+		proxy.geoJSONHeader = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		return proxy, nil
+	}
+}
+
+func isCityDb(db *geoip2.Reader) bool {
+	dbType := db.Metadata().DatabaseType
+	return strings.Contains(dbType, "City") || strings.Contains(dbType, "Enterprise")
+}
+
+func (p *geoProxy) lookupCity(ip net.IP) string {
+	p.dbLock.RLock()
+	defer p.dbLock.RUnlock()
+
+	db := p.dbFor(ip)
+	if db == nil || !isCityDb(db) {
+		return ""
+	}
+
+	record, err := db.City(ip)
+	if err != nil {
+		return ""
+	}
+
+	return record.City.Names["en"]
+}
+
+func (p *geoProxy) setGeoJSONHeader(req *http.Request, ip net.IP, country *geoip2.Country, info countryInfo) {
+	data, err := json.Marshal(geoJSON{
+		Country:   info.isoCode,
+		Continent: country.Continent.Code,
+		City:      p.lookupCity(ip),
+	})
+	if err != nil {
+		return
+	}
+
+	req.Header.Set(p.geoJSONHeader, base64.StdEncoding.EncodeToString(data))
+}
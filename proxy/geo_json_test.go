@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// decodeGeoJSON returns the geo JSON passed by a proxy to the next handler.
+func decodeGeoJSON(t *testing.T, p *geoProxy, addr string) (geoJSON, bool) {
+	t.Helper()
+
+	var header string
+	handler := p.Middleware()(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		header = req.Header.Get("X-Geo-Json")
+	}))
+
+	req := newTestRequest(addr)
+	req.Header.Set("X-Geo-Json", "forged")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(header) == 0 {
+		return geoJSON{}, false
+	}
+
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("header is not base64-encoded: %v", err)
+	}
+
+	var decoded geoJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("header is not JSON: %v", err)
+	}
+
+	return decoded, true
+}
+
+func TestGeoJSONHeader(t *testing.T) {
+	db := writeTestDatabase(t, "GeoLite2-City", map[string]interface{}{
+		"1.1.1.0/24": map[string]interface{}{
+			"country":   map[string]interface{}{"iso_code": "DE"},
+			"continent": map[string]interface{}{"code": "EU"},
+			"city":      map[string]interface{}{"names": map[string]interface{}{"en": "Berlin"}},
+		},
+	})
+
+	p, err := New(0, db, "", WithQuiet(), WithGeoJSONHeader("x-geo-json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	decoded, ok := decodeGeoJSON(t, p, "1.1.1.1")
+	if !ok {
+		t.Fatal("geo JSON header is not set")
+	}
+
+	expected := geoJSON{Country: "DE", Continent: "EU", City: "Berlin"}
+	if decoded != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+}
+
+func TestGeoJSONHeaderWithoutCity(t *testing.T) {
+	db := writeTestDatabase(t, "GeoLite2-Country", map[string]interface{}{
+		"1.1.1.0/24": map[string]interface{}{
+			"country":   map[string]interface{}{"iso_code": "US"},
+			"continent": map[string]interface{}{"code": "NA"},
+		},
+	})
+
+	p, err := New(0, db, "", WithQuiet(), WithGeoJSONHeader("X-Geo-Json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	decoded, ok := decodeGeoJSON(t, p, "1.1.1.1")
+	if !ok {
+		t.Fatal("geo JSON header is not set")
+	}
+
+	expected := geoJSON{Country: "US", Continent: "NA"}
+	if decoded != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+}
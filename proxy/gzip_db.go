@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// openGeoDb opens a database file, gzip-compressed files are decompressed into memory.
+func openGeoDb(path string) (*geoip2.Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		// memory-mapped files are preferred for uncompressed databases
+		return geoip2.Open(path)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+
+	return geoip2.FromBytes(data)
+}
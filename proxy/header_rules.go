@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type headerRule struct {
+	name  string
+	value *regexp.Regexp
+}
+
+// WithBlockIfHeader is used to block requests with a header value matching the regular expression,
+// in addition to other filtering rules. An empty expression blocks requests having the header at all.
+// Repeated options accumulate, a request is blocked when any of them matches.
+func WithBlockIfHeader(name string, valueRegex string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			return nil, configError(ErrNoRule, "blocked header name is not specified")
+		}
+
+		rule := headerRule{name: http.CanonicalHeaderKey(name)}
+		if len(valueRegex) > 0 {
+			value, err := regexp.Compile(valueRegex)
+			if err != nil {
+				return nil, configError(ErrInvalidOption, "invalid %s header pattern: %v", name, err)
+			}
+			rule.value = value
+		}
+
+		proxy.blockedHeaders = append(proxy.blockedHeaders, rule)
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) blockedHeader(req *http.Request) *blockReason {
+	for _, rule := range p.blockedHeaders {
+		values, ok := req.Header[rule.name]
+		if !ok {
+			continue
+		}
+
+		if rule.value == nil {
+			return &blockReason{rule: "header", value: rule.name}
+		}
+
+		for _, value := range values {
+			if rule.value.MatchString(value) {
+				return &blockReason{rule: "header", value: rule.name + ": " + value}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBlockIfHeader(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithBlockIfHeader("x-scanner", ""),
+		WithBlockIfHeader("User-Agent", `(?i)curl|python-requests`),
+	)
+	handler := p.Middleware()(okHandler)
+
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		status  int
+	}{
+		{"no headers", nil, http.StatusOK},
+		{"present header", map[string][]string{"X-Scanner": {""}}, http.StatusForbidden},
+		{"present header with a value", map[string][]string{"X-Scanner": {"yes"}}, http.StatusForbidden},
+		{"matching value", map[string][]string{"User-Agent": {"curl/7.68.0"}}, http.StatusForbidden},
+		{"case-insensitive match", map[string][]string{"User-Agent": {"Python-Requests/2.25"}}, http.StatusForbidden},
+		{"matching second value", map[string][]string{"User-Agent": {"Mozilla/5.0", "curl/7.68.0"}}, http.StatusForbidden},
+		{"other value", map[string][]string{"User-Agent": {"Mozilla/5.0"}}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := newTestRequest("1.1.1.1")
+		for name, values := range test.headers {
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		if res.Code != test.status {
+			t.Errorf("%s: expected %d, got %d", test.name, test.status, res.Code)
+		}
+	}
+
+	if _, err := New(0, "", "", WithBlockIfHeader(" ", "")); err == nil {
+		t.Error("empty header name is accepted")
+	}
+	if _, err := New(0, "", "", WithBlockIfHeader("User-Agent", "(")); err == nil {
+		t.Error("invalid pattern is accepted")
+	}
+}
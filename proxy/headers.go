@@ -0,0 +1,49 @@
+package proxy
+
+import "net/http"
+
+// WithHeaderName is used to pass the country code to the target under the specified headers
+// instead of X-Geo-Country, e.g. X-Country-Code and Cloudfront-Viewer-Country at the same time.
+func WithHeaderName(names ...string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(names) == 0 {
+			return nil, configError(ErrInvalidOption, "header names are not specified")
+		}
+
+		for _, name := range names {
+			if len(name) == 0 {
+				return nil, configError(ErrInvalidOption, "header name must not be empty")
+			}
+		}
+
+		proxy.countryHeaders = names
+		return proxy, nil
+	}
+}
+
+// WithInjectGeoHeader is used to enable or disable passing the country of a client to the target in headers.
+// Headers are passed for allowed and soft-blocked requests by default.
+func WithInjectGeoHeader(inject bool) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.injectGeoHeader = inject
+		return proxy, nil
+	}
+}
+
+// stripGeoHeaders removes geo headers sent by a client, so the target can trust the headers set by the proxy.
+func (p *geoProxy) stripGeoHeaders(header http.Header) {
+	header.Del(geoHeaderName)
+	for _, name := range p.countryHeaders {
+		header.Del(name)
+	}
+
+	if len(p.geoJSONHeader) > 0 {
+		header.Del(p.geoJSONHeader)
+	}
+}
+
+func (p *geoProxy) setCountryHeaders(header http.Header, isoCode string) {
+	for _, name := range p.countryHeaders {
+		header.Set(name, isoCode)
+	}
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// forwardedHeaders returns headers of the request as received by the target.
+func forwardedHeaders(t *testing.T, req *http.Request, opts ...StartOption) http.Header {
+	t.Helper()
+
+	var header http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		header = req.Header.Clone()
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	opts = append([]StartOption{WithQuiet(), WithResolver(countries(map[string]string{"1.1.1.1": "US"}))}, opts...)
+	p, err := New(0, "", backend.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("request is not proxied, got %d", res.Code)
+	}
+
+	return header
+}
+
+func TestHeaderNames(t *testing.T) {
+	names := []string{"X-Country-Code", "X-AppEngine-Country", "Cloudfront-Viewer-Country"}
+	header := forwardedHeaders(t, newTestRequest("1.1.1.1"), WithHeaderName(names...))
+
+	for _, name := range names {
+		if country := header.Get(name); country != "US" {
+			t.Errorf("expected US in %s, got '%s'", name, country)
+		}
+	}
+	if country := header.Get(geoHeaderName); len(country) > 0 {
+		t.Errorf("default header is set along with the configured ones: %s", country)
+	}
+
+	if _, err := New(0, "", "", WithHeaderName()); err == nil {
+		t.Error("empty list of header names is accepted")
+	}
+	if _, err := New(0, "", "", WithHeaderName("X-Country-Code", "")); err == nil {
+		t.Error("empty header name is accepted")
+	}
+}
+
+func TestInjectGeoHeaderDisabled(t *testing.T) {
+	tests := map[string][]StartOption{
+		"allowed":      {WithAllowedCountries([]string{"US"})},
+		"soft-blocked": {WithBlockedCountries([]string{"US"}), WithSoftBlock("X-Geo-Blocked")},
+	}
+
+	for name, opts := range tests {
+		opts = append(opts, WithHeaderName("X-Country-Code"), WithGeoJSONHeader("X-Geo-JSON"), WithInjectGeoHeader(false))
+		header := forwardedHeaders(t, newTestRequest("1.1.1.1"), opts...)
+
+		for _, headerName := range []string{geoHeaderName, "X-Country-Code", "X-Geo-JSON"} {
+			if value := header.Get(headerName); len(value) > 0 {
+				t.Errorf("%s: %s is forwarded while injection is disabled: %s", name, headerName, value)
+			}
+		}
+	}
+}
+
+func TestClientGeoHeadersStripped(t *testing.T) {
+	tests := map[string][]StartOption{
+		"injected":     {},
+		"not injected": {WithInjectGeoHeader(false)},
+		"soft-blocked": {WithBlockedCountries([]string{"US"}), WithSoftBlock("X-Geo-Blocked"), WithInjectGeoHeader(false)},
+	}
+
+	for name, opts := range tests {
+		opts = append(opts, WithHeaderName(geoHeaderName, "X-Country-Code"), WithGeoJSONHeader("X-Geo-JSON"))
+		req := newTestRequest("1.1.1.1")
+		for _, headerName := range []string{geoHeaderName, "X-Country-Code", "X-Geo-JSON"} {
+			req.Header.Set(headerName, "FR")
+		}
+
+		header := forwardedHeaders(t, req, opts...)
+		for _, headerName := range []string{geoHeaderName, "X-Country-Code", "X-Geo-JSON"} {
+			if value := header.Get(headerName); value == "FR" {
+				t.Errorf("%s: %s sent by the client is forwarded", name, headerName)
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// honeypotHeaderName marks requests forwarded to a honeypot.
+const honeypotHeaderName = "X-Geo-Blocked"
+
+// WithHoneypotTarget is used to forward blocked requests to a honeypot service instead of responding to them.
+// Forwarded requests are marked with the X-Geo-Blocked header and carry the same headers as requests
+// forwarded to a block backend.
+func WithHoneypotTarget(honeypotUrl string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if err := validateTarget(honeypotUrl); err != nil {
+			return nil, err
+		}
+
+		target, _ := url.Parse(honeypotUrl)
+		honeypot := proxy.newBlockedRequestsProxy("honeypot", target)
+
+		director := honeypot.Director
+		honeypot.Director = func(req *http.Request) {
+			director(req)
+			req.Header.Set(honeypotHeaderName, "1")
+		}
+
+		proxy.blockResponse = "honeypot"
+		proxy.honeypotUrl = honeypotUrl
+		proxy.addAction(honeypot.ServeHTTP)
+		return proxy, nil
+	}
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type additionalListener struct {
+	addr      string
+	tlsConfig *tls.Config
+}
+
+// WithAdditionalListener is used to serve requests on another address with the same rules and target,
+// e.g. on :443 in addition to the main port. Connections are served over TLS when tlsConfig is not nil,
+// the config must provide a certificate.
+func WithAdditionalListener(addr string, tlsConfig *tls.Config) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, configError(ErrInvalidOption, "invalid listener address '%s'", addr)
+		}
+
+		if tlsConfig != nil && len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil {
+			return nil, configError(ErrInvalidOption, "TLS config of listener '%s' has no certificates", addr)
+		}
+
+		proxy.listeners = append(proxy.listeners, additionalListener{
+			addr:      addr,
+			tlsConfig: tlsConfig,
+		})
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: p.timeouts.readHeader,
+		ReadTimeout:       p.timeouts.read,
+		WriteTimeout:      p.timeouts.write,
+		IdleTimeout:       p.timeouts.idle,
+		MaxHeaderBytes:    p.maxHeaderBytes,
+	}
+}
+
+// startAdditionalListeners starts servers of additional listeners, they are closed on failure.
+func (p *geoProxy) startAdditionalListeners() ([]*http.Server, error) {
+	var servers []*http.Server
+
+	for _, l := range p.listeners {
+		listener, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			for _, server := range servers {
+				_ = server.Close()
+			}
+			return nil, errors.Errorf("Failed to start server on %s: %v\n", l.addr, err)
+		}
+
+		if l.tlsConfig != nil {
+			listener = tls.NewListener(listener, p.listenerTLSConfig(l.tlsConfig))
+		}
+
+		p.logger.Info("starting additional server",
+			zap.String("addr", l.addr),
+			zap.Bool("tls", l.tlsConfig != nil),
+		)
+
+		server := p.newServer(p.mux)
+		servers = append(servers, server)
+
+		go func(addr string) {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				p.logger.Error("additional server has failed",
+					zap.String("addr", addr),
+					zap.Error(err),
+				)
+			}
+		}(l.addr)
+	}
+
+	return servers, nil
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveTestListener serves requests of a proxy by a server configured like the proxy listeners.
+func serveTestListener(t *testing.T, p *geoProxy) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := p.newServer(p.Middleware()(okHandler))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	return listener.Addr().String()
+}
+
+func TestServerReadHeaderTimeout(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"127.0.0.1": "US"})),
+		WithServerTimeouts(100*time.Millisecond, time.Second, time.Second, time.Second),
+	)
+	addr := serveTestListener(t, p)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	started := time.Now()
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rest of the headers is never sent
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _ = ioutil.ReadAll(conn)
+
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("slow connection is not dropped after the header timeout, took %s", elapsed)
+	}
+}
+
+func TestServerMaxHeaderBytes(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"127.0.0.1": "US"})),
+		WithMaxHeaderBytes(1024),
+	)
+	addr := serveTestListener(t, p)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	header := "GET / HTTP/1.1\r\nHost: example.com\r\nX-Large: " + strings.Repeat("a", 8192) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(header)); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expected 431 for large headers, got %d", res.StatusCode)
+	}
+}
+
+func TestMaxHeaderBytesValidation(t *testing.T) {
+	_, err := New(0, "", "", WithMaxHeaderBytes(0))
+	if err == nil || !strings.Contains(err.Error(), "maximum header size") {
+		t.Errorf("zero header size is accepted: %v", err)
+	}
+}
+
+// serverCert issues a certificate of 127.0.0.1 signed by the CA.
+func (ca *testCA) serverCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "server"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// freeAddr returns a local address which is not in use.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func TestAdditionalListeners(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.Header().Set("X-Backend", "yes")
+	}))
+	defer backend.Close()
+
+	ca := newTestCA(t, "ca")
+	plainAddr, tlsAddr := freeAddr(t), freeAddr(t)
+
+	p, err := New(0, "", backend.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"127.0.0.1": "US"})),
+		WithAllowedCountries([]string{"US"}),
+		WithAdditionalListener(plainAddr, nil),
+		WithAdditionalListener(tlsAddr, &tls.Config{Certificates: []tls.Certificate{ca.serverCert(t)}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	p.setupPublicEndpoints()
+	servers, err := p.startAdditionalListeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, server := range servers {
+			_ = server.Close()
+		}
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	for _, url := range []string{"http://" + plainAddr, "https://" + tlsAddr} {
+		res, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("request to %s has failed: %v", url, err)
+		}
+		_ = res.Body.Close()
+
+		if res.StatusCode != http.StatusOK || res.Header.Get("X-Backend") != "yes" {
+			t.Errorf("request to %s is not proxied, got %d", url, res.StatusCode)
+		}
+	}
+}
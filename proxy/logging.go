@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultSamplingInitial    = 100
+	defaultSamplingThereafter = 100
+)
+
+type logSampling struct {
+	initial    int
+	thereafter int
+}
+
+// WithLogSampling is used to rate-limit the per-request decision logs.
+// Every second the first 'initial' identical entries are logged, after that only every 'thereafter' entry is logged.
+// Database and watcher logs are never sampled.
+func WithLogSampling(initial, thereafter int) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if initial <= 0 || thereafter <= 0 {
+			return nil, configError(ErrInvalidOption, "log sampling values must be positive")
+		}
+
+		proxy.sampling = logSampling{initial: initial, thereafter: thereafter}
+		return proxy, nil
+	}
+}
+
+// WithIPAnonymization is used to mask client IP addresses in logs.
+// The last octet of IPv4 and the last 80 bits of IPv6 addresses are zeroed, filtering still uses the full address.
+func WithIPAnonymization() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.anonymizeIP = true
+		return proxy, nil
+	}
+}
+
+func anonymizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32))
+	}
+
+	return ip.Mask(net.CIDRMask(48, 128))
+}
+
+func (p *geoProxy) ipField(ip net.IP) zap.Field {
+	if p.anonymizeIP {
+		ip = anonymizeIP(ip)
+	}
+
+	return zap.String("ip", ip.String())
+}
+
+func (p *geoProxy) addrField(addr string) zap.Field {
+	if p.anonymizeIP {
+		if ip := getIP(addr); ip != nil {
+			return zap.String("addr", anonymizeIP(ip).String())
+		}
+		return zap.Skip()
+	}
+
+	return zap.String("addr", addr)
+}
+
+// WithLogFile is used to write logs to a file in addition to stderr, the file is rotated when it reaches maxSizeMB megabytes.
+// At most maxBackups rotated files are kept for maxAgeDays days, zero values keep all files.
+func WithLogFile(path string, maxSizeMB, maxBackups, maxAgeDays int) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(path) == 0 {
+			return nil, configError(ErrInvalidOption, "log file path is not specified")
+		}
+
+		if maxSizeMB <= 0 || maxBackups < 0 || maxAgeDays < 0 {
+			return nil, configError(ErrInvalidOption, "invalid log file rotation settings")
+		}
+
+		proxy.logFile = &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSizeMB,
+			MaxBackups: maxBackups,
+			MaxAge:     maxAgeDays,
+		}
+		return proxy, nil
+	}
+}
+
+// WithQuiet is used to suppress all logs except errors, e.g. failures to reload a GeoIP database.
+func WithQuiet() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.quiet = true
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) setupLoggers() error {
+	cfg := zap.NewProductionConfig()
+	cfg.Sampling = nil
+	if p.quiet {
+		cfg.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	}
+
+	var opts []zap.Option
+	if p.logFile != nil {
+		opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			// logs are still written to stderr, so startup errors are visible on the console
+			return zapcore.NewTee(core, zapcore.NewCore(
+				zapcore.NewJSONEncoder(cfg.EncoderConfig),
+				zapcore.AddSync(p.logFile),
+				cfg.Level,
+			))
+		}))
+	}
+
+	logger, err := cfg.Build(opts...)
+	if err != nil {
+		return errors.Wrap(err, "failed to create logger")
+	}
+
+	p.logger = logger
+	p.requestLogger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSampler(core, time.Second, p.sampling.initial, p.sampling.thereafter)
+	}))
+
+	return nil
+}
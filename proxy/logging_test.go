@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"geofilter/internal/mmdbtest"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestLogSampling(t *testing.T) {
+	p, path := openLoggingProxy(t,
+		WithResolver(countries(map[string]string{"1.2.3.4": "DE"})),
+		WithAllowedCountries([]string{"US"}),
+		WithLogSampling(2, 5),
+	)
+
+	const requests = 10
+	for i := 0; i < requests; i++ {
+		p.Middleware()(okHandler).ServeHTTP(httptest.NewRecorder(), newTestRequest("1.2.3.4"))
+	}
+	_ = p.Close()
+
+	// 1st, 2nd and 7th entries are logged within a second
+	logged := len(findLogs(readLogs(t, path), "forbidden country"))
+	if logged < 2 || logged >= requests {
+		t.Errorf("expected repeated decisions to be sampled, %d of %d are logged", logged, requests)
+	}
+	if stats := p.Stats(); stats.Blocked != requests {
+		t.Errorf("sampling must not affect counters, got %+v", stats)
+	}
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"1.2.3.4", "1.2.3.0"},
+		{"::ffff:1.2.3.4", "1.2.3.0"},
+		{"2001:db8:1234:5678:9abc::1", "2001:db8:1234::"},
+		{"2001:db8:1234:ffff:ffff:ffff:ffff:ffff", "2001:db8:1234::"},
+	}
+
+	for _, test := range tests {
+		if actual := anonymizeIP(net.ParseIP(test.ip)).String(); actual != test.expected {
+			t.Errorf("%s is anonymized as %s, expected %s", test.ip, actual, test.expected)
+		}
+	}
+}
+
+func TestAnonymizedLogs(t *testing.T) {
+	p, path := openLoggingProxy(t,
+		WithResolver(countries(map[string]string{
+			"1.2.3.4":                    "DE",
+			"2001:db8:1234:5678:9abc::1": "DE",
+		})),
+		WithAllowedCountries([]string{"US"}),
+		WithIPAnonymization(),
+	)
+
+	p.Middleware()(okHandler).ServeHTTP(httptest.NewRecorder(), newTestRequest("1.2.3.4"))
+	p.Middleware()(okHandler).ServeHTTP(httptest.NewRecorder(), newTestRequest("2001:db8:1234:5678:9abc::1"))
+	_ = p.Close()
+
+	entries := findLogs(readLogs(t, path), "forbidden country")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 blocked requests to be logged, got %d", len(entries))
+	}
+	if ip := entries[0]["ip"]; ip != "1.2.3.0" {
+		t.Errorf("IPv4 address is logged as %v", ip)
+	}
+	if ip := entries[1]["ip"]; ip != "2001:db8:1234::" {
+		t.Errorf("IPv6 address is logged as %v", ip)
+	}
+}
+
+func TestLogFileRotation(t *testing.T) {
+	p, path := openLoggingProxy(t, WithResolver(countries(nil)))
+
+	// the file is rotated after 1MB
+	large := strings.Repeat("a", 50*1024)
+	for i := 0; i < 25; i++ {
+		p.logger.Info("large entry", zap.String("data", large))
+	}
+	_ = p.Close()
+
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected the log file to be rotated once, got %d files", len(files))
+	}
+
+	if entries := findLogs(readLogs(t, path), "large entry"); len(entries) == 0 || len(entries) == 25 {
+		t.Errorf("expected the current log file to contain entries after the rotation, got %d", len(entries))
+	}
+}
+
+func TestLogFileKeepsStderr(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() {
+		os.Stderr = stderr
+	}()
+
+	p, path := openLoggingProxy(t, WithResolver(countries(nil)))
+	os.Stderr = stderr
+
+	p.logger.Error("startup error")
+	_ = p.Close()
+	_ = w.Close()
+
+	console, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(console), "startup error") {
+		t.Error("log entry is not written to stderr")
+	}
+	if len(findLogs(readLogs(t, path), "startup error")) != 1 {
+		t.Error("log entry is not written to the file")
+	}
+}
+
+func TestQuietLogs(t *testing.T) {
+	db := writeTestDatabase(t, "GeoLite2-Country", map[string]interface{}{
+		"1.1.1.0/24": mmdbtest.Country("US"),
+		"2.2.2.0/24": mmdbtest.Country("RU"),
+	})
+	path := filepath.Join(filepath.Dir(db), "geofilter.log")
+
+	p, err := New(0, db, "",
+		WithLogFile(path, 1, 0, 0),
+		WithQuiet(),
+		WithAutoReload(),
+		WithAllowedCountries([]string{"US"}),
+		WithIgnoredCountries([]string{"Atlantis"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	handler := p.Middleware()(okHandler)
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("1.1.1.1"))
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("2.2.2.2"))
+
+	if err := ioutil.WriteFile(db, []byte("not a database"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloadFailed := func() bool {
+		// the log file is created by the first entry
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+		return len(findLogs(readLogs(t, path), "failed to reload Geo DB")) > 0
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !reloadFailed() {
+		if time.Now().After(deadline) {
+			t.Fatal("reload failure is not logged in the quiet mode")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, entry := range readLogs(t, path) {
+		if entry["level"] != "error" {
+			t.Errorf("unexpected %s log in the quiet mode: %s", entry["level"], entry["msg"])
+		}
+	}
+}
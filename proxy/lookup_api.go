@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLookupRateLimit  = 10
+	defaultLookupRateWindow = time.Second
+)
+
+type lookupResponse struct {
+	IP string `json:"ip"`
+	geoJSON
+}
+
+type lookupError struct {
+	Error string `json:"error"`
+}
+
+// WithLookupAPI is used to serve geo data of addresses as JSON on the specified path instead of proxying requests.
+// The address is passed in the ip query parameter, e.g. /?ip=1.2.3.4, the address of a caller is used when it is omitted.
+// Each caller is limited to 10 requests per second unless WithLookupRateLimit is used. Callers are limited
+// by their connection addresses, client address headers are only honored for peers configured by WithTrustedProxies.
+func WithLookupAPI(path string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		path = strings.TrimSpace(path)
+		if !strings.HasPrefix(path, "/") {
+			return nil, configError(ErrInvalidOption, "invalid lookup API path '%s', expected an absolute path", path)
+		}
+
+		proxy.lookupAPIPath = path
+		if proxy.lookupLimiter == nil {
+			proxy.lookupLimiter = newTokenLimiter(defaultLookupRateLimit, defaultLookupRateWindow)
+		}
+
+		return proxy, nil
+	}
+}
+
+// WithLookupRateLimit is used to limit a number of lookup API requests a caller can make within a time window.
+func WithLookupRateLimit(limit int, window time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if limit <= 0 || window <= 0 {
+			return nil, configError(ErrInvalidOption, "invalid lookup rate limit %d per %s", limit, window)
+		}
+
+		proxy.lookupLimiter = newTokenLimiter(limit, window)
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) lookupAPIHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		res.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+		writeLookupError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	caller := getIP(p.clientAddr(req))
+	if caller == nil {
+		writeLookupError(res, http.StatusBadRequest, "can not get caller address")
+		return
+	}
+
+	// client address headers of untrusted peers can be rotated to bypass the limit
+	limited := caller
+	if p.trustedProxies == nil || !p.isTrustedPeer(req) {
+		if peer := getIP(req.RemoteAddr); peer != nil {
+			limited = peer
+		}
+	}
+
+	if !p.lookupLimiter.allow(limited.String(), p.clock.Now()) {
+		writeLookupError(res, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	ip := caller
+	if param := req.URL.Query().Get("ip"); len(param) > 0 {
+		if ip = net.ParseIP(strings.TrimSpace(param)); ip == nil {
+			writeLookupError(res, http.StatusBadRequest, "invalid ip parameter")
+			return
+		}
+
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+	}
+
+	country, err := p.lookup(req.Context(), ip)
+	if err != nil {
+		writeLookupError(res, http.StatusNotFound, "address is not found")
+		return
+	}
+
+	info := p.selectCountry(country)
+	res.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(res).Encode(lookupResponse{
+		IP: ip.String(),
+		geoJSON: geoJSON{
+			Country:   info.isoCode,
+			Continent: country.Continent.Code,
+			City:      p.lookupCity(ip),
+		},
+	})
+}
+
+func writeLookupError(res http.ResponseWriter, status int, message string) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(lookupError{Error: message})
+}
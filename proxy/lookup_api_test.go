@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLookupRateLimitKey(t *testing.T) {
+	resolver := WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "US", "3.3.3.3": "US"}))
+
+	lookup := func(p *geoProxy, peer, forwarded string) int {
+		req := newTestRequest(peer)
+		req.Header.Set("X-Forwarded-For", forwarded)
+		res := httptest.NewRecorder()
+		p.lookupAPIHandler(res, req)
+		return res.Code
+	}
+
+	// without trusted proxies a client can not rotate X-Forwarded-For to bypass the limit
+	p := openTestProxy(t, resolver, WithLookupAPI("/lookup"), WithLookupRateLimit(1, time.Minute))
+	if code := lookup(p, "1.1.1.1", "2.2.2.2"); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, code)
+	}
+	if code := lookup(p, "1.1.1.1", "3.3.3.3"); code != http.StatusTooManyRequests {
+		t.Errorf("expected %d for a rotated header, got %d", http.StatusTooManyRequests, code)
+	}
+
+	// clients behind a trusted proxy are limited by their own addresses
+	p = openTestProxy(t, resolver,
+		WithLookupAPI("/lookup"),
+		WithLookupRateLimit(1, time.Minute),
+		WithTrustedProxies([]string{"10.0.0.0/8"}),
+	)
+	if code := lookup(p, "10.0.0.1", "2.2.2.2"); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, code)
+	}
+	if code := lookup(p, "10.0.0.1", "3.3.3.3"); code != http.StatusOK {
+		t.Errorf("expected %d for another client of a trusted proxy, got %d", http.StatusOK, code)
+	}
+	if code := lookup(p, "10.0.0.1", "3.3.3.3"); code != http.StatusTooManyRequests {
+		t.Errorf("expected %d for a repeated client, got %d", http.StatusTooManyRequests, code)
+	}
+	if code := lookup(p, "1.1.1.1", "2.2.2.2"); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, code)
+	}
+	if code := lookup(p, "1.1.1.1", "3.3.3.3"); code != http.StatusTooManyRequests {
+		t.Errorf("expected %d for a rotated header of an untrusted peer, got %d", http.StatusTooManyRequests, code)
+	}
+}
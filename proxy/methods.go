@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithAllowedMethods is used to reject requests with other HTTP methods with 405 Method Not Allowed
+// before they are filtered, e.g. to serve a read-only mirror.
+func WithAllowedMethods(methods []string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(methods) == 0 {
+			return nil, configError(ErrNoRule, "allowed methods are not specified")
+		}
+
+		allowed := make(map[string]bool)
+		var names []string
+		for _, method := range methods {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if len(method) == 0 {
+				return nil, configError(ErrInvalidOption, "method must not be empty")
+			}
+
+			if !allowed[method] {
+				allowed[method] = true
+				names = append(names, method)
+			}
+		}
+
+		proxy.allowedMethods = allowed
+		proxy.allowHeader = strings.Join(names, ", ")
+		return proxy, nil
+	}
+}
+
+// rejectMethod responds with 405 Method Not Allowed when a request method is not allowed.
+func (p *geoProxy) rejectMethod(res http.ResponseWriter, req *http.Request) bool {
+	if p.allowedMethods == nil || p.allowedMethods[req.Method] {
+		return false
+	}
+
+	res.Header().Set("Allow", p.allowHeader)
+	res.WriteHeader(http.StatusMethodNotAllowed)
+	return true
+}
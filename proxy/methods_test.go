@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedMethods(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithAllowedMethods([]string{"get", " HEAD", "GET"}),
+	)
+	handler := p.Middleware()(okHandler)
+
+	tests := []struct {
+		addr   string
+		method string
+		status int
+	}{
+		{"1.1.1.1", http.MethodGet, http.StatusOK},
+		{"1.1.1.1", http.MethodHead, http.StatusOK},
+		{"1.1.1.1", http.MethodPost, http.StatusMethodNotAllowed},
+		{"2.2.2.2", http.MethodGet, http.StatusForbidden},
+		// methods are rejected before requests are filtered
+		{"2.2.2.2", http.MethodPost, http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		req := newTestRequest(test.addr)
+		req.Method = test.method
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		if res.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.addr, test.status, res.Code)
+		}
+
+		allow := res.Header().Get("Allow")
+		if test.status == http.StatusMethodNotAllowed && allow != "GET, HEAD" {
+			t.Errorf("%s %s: expected Allow header 'GET, HEAD', got '%s'", test.method, test.addr, allow)
+		}
+	}
+
+	if _, err := New(0, "", "", WithAllowedMethods([]string{"GET", " "})); err == nil {
+		t.Error("empty method is accepted")
+	}
+}
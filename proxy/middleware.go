@@ -0,0 +1,28 @@
+package proxy
+
+import "net/http"
+
+// Middleware returns a middleware which filters requests before passing them to the next handler.
+// Blocked requests are handled by the configured block action, the target URL is not used.
+// Open must be called before the middleware handles requests.
+func (p *geoProxy) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		handler := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			req, allowed := p.filterRequest(res, req)
+			if p.decisionStream != nil {
+				p.publishDecision(req)
+			}
+			if !allowed {
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+
+		if p.accessLog != nil {
+			return p.withAccessLog(handler)
+		}
+
+		return handler
+	}
+}
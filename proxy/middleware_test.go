@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+	)
+
+	calls := 0
+	handler := p.Middleware()(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		calls++
+		res.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		addr     string
+		expected int
+		calls    int
+	}{
+		{"1.1.1.1", http.StatusNoContent, 1},
+		{"2.2.2.2", http.StatusForbidden, 1},
+	}
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest(test.addr))
+
+		if res.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.addr, test.expected, res.Code)
+		}
+		if calls != test.calls {
+			t.Errorf("%s: expected the next handler to be called %d times, got %d", test.addr, test.calls, calls)
+		}
+	}
+}
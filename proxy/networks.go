@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// networkFilterFunc returns a reason to block an address, it returns nil when the address is allowed.
+type networkFilterFunc func(net.IP) *blockReason
+
+// WithAllowedNetworks is used to configure a proxy to allow requests coming from a list of specified networks.
+// Networks are specified in CIDR notation (1.2.3.0/24), as ranges (1.2.3.0-1.2.3.255) or as single addresses.
+// All other requests will be blocked, unless WithCIDRPrecedence makes the networks override country rules.
+func WithAllowedNetworks(networks []string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(networks) == 0 {
+			return nil, configError(ErrNoRule, "allowed networks are not specified")
+		}
+
+		allowedNetworks, err := parseNetworks(networks)
+		if err != nil {
+			return nil, err
+		}
+
+		proxy.networkFilter = func(ip net.IP) *blockReason {
+			if containsIP(allowedNetworks, ip) {
+				return nil
+			}
+
+			return &blockReason{rule: "network", value: "not in allowed networks"}
+		}
+		proxy.allowedNetworks = allowedNetworks
+
+		return proxy, nil
+	}
+}
+
+// WithBlockedNetworks is used to configure a proxy to block requests coming from a list of specified networks.
+// Networks are specified in CIDR notation (1.2.3.0/24), as ranges (1.2.3.0-1.2.3.255) or as single addresses.
+// All other requests will be allowed.
+func WithBlockedNetworks(networks []string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(networks) == 0 {
+			return nil, configError(ErrNoRule, "blocked networks are not specified")
+		}
+
+		blockedNetworks, err := parseNetworks(networks)
+		if err != nil {
+			return nil, err
+		}
+
+		proxy.networkFilter = func(ip net.IP) *blockReason {
+			if n := matchNetwork(blockedNetworks, ip); n != nil {
+				return &blockReason{rule: "network", value: n.String()}
+			}
+
+			return nil
+		}
+		proxy.allowedNetworks = nil
+
+		return proxy, nil
+	}
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	return matchNetwork(networks, ip) != nil
+}
+
+// matchNetwork returns the first network containing an address.
+func matchNetwork(networks []*net.IPNet, ip net.IP) *net.IPNet {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func parseNetworks(networks []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(networks))
+	for _, n := range networks {
+		parsed, err := parseNetwork(strings.TrimSpace(n))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, parsed...)
+	}
+
+	return result, nil
+}
+
+func parseNetwork(network string) ([]*net.IPNet, error) {
+	if strings.Contains(network, "/") {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, configError(ErrInvalidOption, "invalid network '%s'", network)
+		}
+		return []*net.IPNet{ipNet}, nil
+	}
+
+	if i := strings.Index(network, "-"); i >= 0 {
+		start := net.ParseIP(strings.TrimSpace(network[:i]))
+		end := net.ParseIP(strings.TrimSpace(network[i+1:]))
+		if start == nil || end == nil {
+			return nil, configError(ErrInvalidOption, "invalid range '%s'", network)
+		}
+		return rangeToNetworks(start, end)
+	}
+
+	ip := net.ParseIP(network)
+	if ip == nil {
+		return nil, configError(ErrInvalidOption, "invalid address '%s'", network)
+	}
+
+	return rangeToNetworks(ip, ip)
+}
+
+// rangeToNetworks converts an inclusive range of addresses to a minimal list of CIDR networks.
+func rangeToNetworks(start net.IP, end net.IP) ([]*net.IPNet, error) {
+	bits := net.IPv6len * 8
+	if start4, end4 := start.To4(), end.To4(); start4 != nil || end4 != nil {
+		if start4 == nil || end4 == nil {
+			return nil, configError(ErrInvalidOption, "range %s-%s mixes IPv4 and IPv6 addresses", start, end)
+		}
+		start, end = start4, end4
+		bits = net.IPv4len * 8
+	}
+
+	if bytes.Compare(start, end) > 0 {
+		return nil, configError(ErrInvalidOption, "range start %s is greater than its end %s", start, end)
+	}
+
+	var networks []*net.IPNet
+
+	current := new(big.Int).SetBytes(start)
+	last := new(big.Int).SetBytes(end)
+	one := big.NewInt(1)
+
+	for current.Cmp(last) <= 0 {
+		// find the largest block aligned at the current address which does not exceed the range end
+		size := int(current.TrailingZeroBits())
+		if current.Sign() == 0 {
+			size = bits
+		}
+		for size > 0 {
+			blockEnd := new(big.Int).Lsh(one, uint(size))
+			blockEnd.Add(blockEnd, current).Sub(blockEnd, one)
+			if blockEnd.Cmp(last) <= 0 {
+				break
+			}
+			size--
+		}
+
+		ip := make(net.IP, bits/8)
+		b := current.Bytes()
+		copy(ip[len(ip)-len(b):], b)
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits-size, bits)})
+
+		current.Add(current, new(big.Int).Lsh(one, uint(size)))
+	}
+
+	return networks, nil
+}
+
+// NormalizeNetworks converts networks in the notations accepted by WithAllowedNetworks
+// to a list of networks in CIDR notation, ranges are split into covering networks.
+func NormalizeNetworks(networks []string) ([]string, error) {
+	parsed, err := parseNetworks(networks)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(parsed))
+	for _, n := range parsed {
+		result = append(result, n.String())
+	}
+
+	return result, nil
+}
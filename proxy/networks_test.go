@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRangeToNetworks(t *testing.T) {
+	tests := []struct {
+		start    string
+		end      string
+		expected string
+	}{
+		{"10.0.0.0", "10.0.0.255", "10.0.0.0/24"},
+		{"10.0.0.0", "10.0.0.9", "10.0.0.0/29 10.0.0.8/31"},
+		{"10.0.0.1", "10.0.0.6", "10.0.0.1/32 10.0.0.2/31 10.0.0.4/31 10.0.0.6/32"},
+		{"192.168.1.5", "192.168.1.5", "192.168.1.5/32"},
+		{"0.0.0.0", "255.255.255.255", "0.0.0.0/0"},
+		{"10.0.0.255", "10.0.1.0", "10.0.0.255/32 10.0.1.0/32"},
+		{"2001:db8::", "2001:db8::ffff", "2001:db8::/112"},
+		{"2001:db8::1", "2001:db8::2", "2001:db8::1/128 2001:db8::2/128"},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "::/0"},
+	}
+
+	for _, test := range tests {
+		networks, err := rangeToNetworks(net.ParseIP(test.start), net.ParseIP(test.end))
+		if err != nil {
+			t.Errorf("%s-%s: %v", test.start, test.end, err)
+			continue
+		}
+
+		var cidrs []string
+		for _, n := range networks {
+			cidrs = append(cidrs, n.String())
+		}
+		if actual := strings.Join(cidrs, " "); actual != test.expected {
+			t.Errorf("%s-%s: expected %s, got %s", test.start, test.end, test.expected, actual)
+		}
+	}
+}
+
+func TestRangeToNetworksErrors(t *testing.T) {
+	tests := [][2]string{
+		{"10.0.0.9", "10.0.0.0"},
+		{"10.0.0.0", "2001:db8::1"},
+	}
+
+	for _, test := range tests {
+		if _, err := rangeToNetworks(net.ParseIP(test[0]), net.ParseIP(test[1])); err == nil {
+			t.Errorf("%s-%s: expected an error", test[0], test[1])
+		}
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := parseNetworks([]string{"10.1.0.0/16", " 10.0.0.0-10.0.0.3 ", "192.168.0.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cidrs []string
+	for _, n := range networks {
+		cidrs = append(cidrs, n.String())
+	}
+	expected := "10.1.0.0/16 10.0.0.0/30 192.168.0.1/32 2001:db8::/32"
+	if actual := strings.Join(cidrs, " "); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "10.0.0.x-10.0.0.9", "not an address"} {
+		if _, err := parseNetworks([]string{invalid}); err == nil {
+			t.Errorf("invalid network '%s' is parsed", invalid)
+		}
+	}
+}
+
+func TestCIDRPrecedence(t *testing.T) {
+	resolver := WithResolver(countries(map[string]string{"10.1.2.3": "RU", "10.2.0.1": "RU", "1.1.1.1": "US"}))
+	rules := []StartOption{
+		resolver,
+		WithBlockedCountries([]string{"RU"}),
+		WithAllowedNetworks([]string{"10.1.0.0/16"}),
+	}
+
+	tests := []struct {
+		addr       string
+		precedence int
+		plain      int
+	}{
+		// the partner subnet of a blocked country
+		{"10.1.2.3", http.StatusOK, http.StatusForbidden},
+		{"10.2.0.1", http.StatusForbidden, http.StatusForbidden},
+		// other networks are filtered by countries only with the precedence
+		{"1.1.1.1", http.StatusOK, http.StatusForbidden},
+	}
+
+	withPrecedence := openTestProxy(t, append(rules, WithCIDRPrecedence("country"))...).Middleware()(okHandler)
+	plain := openTestProxy(t, rules...).Middleware()(okHandler)
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		withPrecedence.ServeHTTP(res, newTestRequest(test.addr))
+		if res.Code != test.precedence {
+			t.Errorf("%s with CIDR precedence: expected %d, got %d", test.addr, test.precedence, res.Code)
+		}
+
+		res = httptest.NewRecorder()
+		plain.ServeHTTP(res, newTestRequest(test.addr))
+		if res.Code != test.plain {
+			t.Errorf("%s without CIDR precedence: expected %d, got %d", test.addr, test.plain, res.Code)
+		}
+	}
+}
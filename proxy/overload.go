@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const overloadHeaderName = "X-Overloaded"
+
+// overloadDetector tracks overload signals of the target and sheds requests while it is overloaded.
+type overloadDetector struct {
+	lock     sync.Mutex
+	cooldown time.Duration
+	until    time.Time
+}
+
+// WithOverloadDetection is used to respond with 429 Too Many Requests when the target signals overload
+// with an "X-Overloaded: true" header or with 503 Service Unavailable and a Retry-After header.
+// Following requests are answered with 429 without reaching the target until the Retry-After delay passes,
+// or for the cooldown when the target does not specify a delay.
+func WithOverloadDetection(cooldown time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if cooldown <= 0 {
+			return nil, configError(ErrInvalidOption, "overload cooldown must be positive")
+		}
+
+		proxy.overload = &overloadDetector{cooldown: cooldown}
+		return proxy, nil
+	}
+}
+
+// shedding returns the remaining time requests are shed for.
+func (d *overloadDetector) shedding(now time.Time) (time.Duration, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if now.Before(d.until) {
+		return d.until.Sub(now), true
+	}
+
+	return 0, false
+}
+
+func (d *overloadDetector) modifyResponse(clock Clock) func(*http.Response) error {
+	return func(res *http.Response) error {
+		overloaded := strings.EqualFold(strings.TrimSpace(res.Header.Get(overloadHeaderName)), "true")
+		retryAfter := res.Header.Get("Retry-After")
+		if !overloaded && (res.StatusCode != http.StatusServiceUnavailable || len(retryAfter) == 0) {
+			return nil
+		}
+
+		now := clock.Now()
+		delay, ok := parseRetryAfter(retryAfter, now)
+		if !ok {
+			delay = d.cooldown
+		}
+
+		d.lock.Lock()
+		if until := now.Add(delay); until.After(d.until) {
+			d.until = until
+		}
+		d.lock.Unlock()
+
+		res.StatusCode = http.StatusTooManyRequests
+		res.Status = strconv.Itoa(http.StatusTooManyRequests) + " " + http.StatusText(http.StatusTooManyRequests)
+		res.Header.Set("Retry-After", retryAfterSeconds(delay))
+		return nil
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now), true
+	}
+
+	return 0, false
+}
+
+func retryAfterSeconds(delay time.Duration) string {
+	seconds := int64((delay + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return strconv.FormatInt(seconds, 10)
+}
+
+func writeOverloaded(res http.ResponseWriter, delay time.Duration) {
+	res.Header().Set("Retry-After", retryAfterSeconds(delay))
+	res.WriteHeader(http.StatusTooManyRequests)
+}
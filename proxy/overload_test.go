@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOverloadSheddingKeepsBreakerProbe(t *testing.T) {
+	clock := newFakeClock()
+	p := openTestProxy(t,
+		WithResolver(countries(nil)),
+		WithCircuitBreaker(1, time.Minute),
+		WithOverloadDetection(time.Minute),
+		WithClock(clock),
+	)
+
+	p.breaker.failure(clock.Now())
+	p.overload.until = clock.Now().Add(10 * time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	res := httptest.NewRecorder()
+	p.serveReverseProxy(res, newTestRequest("1.1.1.1"))
+
+	if res.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 while the target is overloaded, got %d", res.Code)
+	}
+	if p.breaker.probing || p.breaker.state != breakerOpen {
+		t.Error("shed request has taken the probe of the circuit breaker")
+	}
+}
@@ -0,0 +1,57 @@
+package proxy
+
+// Policy is a composite filtering policy which allows all requests except the ones coming from
+// blocked countries, autonomous systems or networks. A request is allowed only when it passes all conditions.
+type Policy struct {
+	countries []string
+	asns      []uint
+	networks  []string
+}
+
+// NewPolicy creates an empty policy allowing all requests.
+func NewPolicy() *Policy {
+	return &Policy{}
+}
+
+// BlockCountries adds alpha-2 codes of blocked countries.
+func (p *Policy) BlockCountries(countries ...string) *Policy {
+	p.countries = append(p.countries, countries...)
+	return p
+}
+
+// BlockASNs adds numbers of blocked autonomous systems. Requires WithASNDatabase.
+func (p *Policy) BlockASNs(asns ...uint) *Policy {
+	p.asns = append(p.asns, asns...)
+	return p
+}
+
+// BlockCIDRs adds blocked networks in the notations accepted by WithBlockedNetworks.
+func (p *Policy) BlockCIDRs(networks ...string) *Policy {
+	p.networks = append(p.networks, networks...)
+	return p
+}
+
+// Build returns an option configuring a proxy with the policy.
+func (p *Policy) Build() StartOption {
+	var opts []StartOption
+	if len(p.countries) > 0 {
+		opts = append(opts, WithBlockedCountries(p.countries))
+	}
+	if len(p.asns) > 0 {
+		opts = append(opts, WithBlockedASNs(p.asns))
+	}
+	if len(p.networks) > 0 {
+		opts = append(opts, WithBlockedNetworks(p.networks))
+	}
+
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		var err error
+		for _, opt := range opts {
+			if proxy, err = opt(proxy); err != nil {
+				return nil, err
+			}
+		}
+
+		return proxy, nil
+	}
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	asnDb := writeTestDatabase(t, "GeoLite2-ASN", map[string]interface{}{
+		"3.3.3.0/24": map[string]interface{}{"autonomous_system_number": uint32(64496)},
+		"4.4.4.0/24": map[string]interface{}{"autonomous_system_number": uint32(64500)},
+	})
+
+	policy := NewPolicy().
+		BlockCountries("RU").
+		BlockASNs(64496).
+		BlockCIDRs("10.0.0.0/8")
+
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{
+			"1.1.1.1":  "US",
+			"2.2.2.2":  "RU",
+			"3.3.3.3":  "US",
+			"4.4.4.4":  "US",
+			"10.1.1.1": "US",
+		})),
+		WithASNDatabase(asnDb),
+		policy.Build(),
+	)
+	handler := p.Middleware()(okHandler)
+
+	expected := map[string]int{
+		"1.1.1.1":  http.StatusOK,
+		"2.2.2.2":  http.StatusForbidden,
+		"3.3.3.3":  http.StatusForbidden,
+		"4.4.4.4":  http.StatusOK,
+		"10.1.1.1": http.StatusForbidden,
+	}
+
+	for addr, status := range expected {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest(addr))
+
+		if res.Code != status {
+			t.Errorf("%s: expected %d, got %d", addr, status, res.Code)
+		}
+	}
+}
+
+func TestEmptyPolicy(t *testing.T) {
+	p := openTestProxy(t, WithResolver(countries(map[string]string{"2.2.2.2": "RU"})), NewPolicy().Build())
+
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("2.2.2.2"))
+
+	if res.Code != http.StatusOK {
+		t.Errorf("empty policy blocks requests with %d", res.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+)
+
+// WithCIDRPrecedence is used to make networks allowed by WithAllowedNetworks override other rules.
+// The only supported value of over is "country". Rules are resolved in the following order:
+//
+//  1. requests from allowed networks are allowed, their countries are resolved only to pass them to the target
+//  2. other requests are filtered by the PTR, ASN, trait and country rules
+//
+// Without the option allowed networks are an allow list checked before the other rules.
+func WithCIDRPrecedence(over string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if over != "country" {
+			return nil, configError(ErrInvalidOption, "unsupported CIDR precedence over '%s', expected country", over)
+		}
+
+		proxy.cidrPrecedence = true
+		return proxy, nil
+	}
+}
+
+// allowNetwork passes a request from an explicitly allowed network further.
+func (p *geoProxy) allowNetwork(req *http.Request, ip net.IP) (*http.Request, bool) {
+	country, err := p.lookup(req.Context(), ip)
+	if err != nil {
+		p.countRequest(&p.stats.allowed, "allowed")
+		return withDecision(req, Decision{Allowed: true}), true
+	}
+
+	info := p.selectCountry(country)
+	req = withDecision(req, Decision{Allowed: true, Country: info.isoCode})
+	if p.injectGeoHeader {
+		p.setCountryHeaders(req.Header, info.isoCode)
+		if len(p.geoJSONHeader) > 0 {
+			p.setGeoJSONHeader(req, ip, country, info)
+		}
+	}
+
+	p.countRequest(&p.stats.allowed, "allowed")
+	return req, true
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+const defaultPrefixCacheSize = 1 << 16
+
+var (
+	ipv4PrefixMask = net.CIDRMask(24, 32)
+	ipv6PrefixMask = net.CIDRMask(48, 128)
+)
+
+type cacheEntry struct {
+	country *geoip2.Country
+	// expires is a zero time for entries which do not expire
+	expires time.Time
+}
+
+// prefixCache memoizes lookup results by /24 IPv4 and /48 IPv6 prefixes.
+type prefixCache struct {
+	lock    sync.RWMutex
+	entries map[string]cacheEntry
+	size    int
+}
+
+// WithPrefixCache is used to cache lookup results by network prefixes: /24 for IPv4 and /48 for IPv6
+// addresses, since clients of the same prefix are almost always located in the same country.
+// The cache is cleared when a GeoIP database is reloaded. It is not used together with WithBlockAnonymous,
+// since anonymous proxy traits are specific to an address rather than to a prefix.
+func WithPrefixCache() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.cache = newPrefixCache(defaultPrefixCacheSize)
+		return proxy, nil
+	}
+}
+
+// WithLookupCacheTTL is used to expire cached lookup results after the specified time, so changes of
+// a database are picked up even without a reload. The option enables the prefix cache.
+func WithLookupCacheTTL(ttl time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if ttl <= 0 {
+			return nil, configError(ErrInvalidOption, "lookup cache TTL must be positive")
+		}
+
+		if proxy.cache == nil {
+			proxy.cache = newPrefixCache(defaultPrefixCacheSize)
+		}
+		proxy.cacheTTL = ttl
+		return proxy, nil
+	}
+}
+
+// checkCacheSupport disables the cache when the filters depend on traits of individual addresses.
+func (p *geoProxy) checkCacheSupport() {
+	if p.cache != nil && p.blockAnonymous {
+		p.logger.Warn("lookup cache is disabled, anonymous proxies are detected by individual addresses")
+		p.cache = nil
+	}
+}
+
+// cacheExpiry returns an expiration time of a cache entry created now.
+func (p *geoProxy) cacheExpiry() time.Time {
+	if p.cacheTTL == 0 {
+		return time.Time{}
+	}
+
+	return p.clock.Now().Add(p.cacheTTL)
+}
+
+func newPrefixCache(size int) *prefixCache {
+	return &prefixCache{
+		entries: make(map[string]cacheEntry),
+		size:    size,
+	}
+}
+
+func prefixKey(ip net.IP) string {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return string(ipv4.Mask(ipv4PrefixMask))
+	}
+
+	return string(ip.Mask(ipv6PrefixMask))
+}
+
+func (c *prefixCache) get(ip net.IP, now time.Time) (*geoip2.Country, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	entry, ok := c.entries[prefixKey(ip)]
+	if !ok || (!entry.expires.IsZero() && !now.Before(entry.expires)) {
+		return nil, false
+	}
+	return entry.country, true
+}
+
+func (c *prefixCache) put(ip net.IP, country *geoip2.Country, expires time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// a full cache is dropped entirely, which is cheap and good enough for skewed traffic
+	if len(c.entries) >= c.size {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[prefixKey(ip)] = cacheEntry{country: country, expires: expires}
+}
+
+func (c *prefixCache) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = make(map[string]cacheEntry)
+}
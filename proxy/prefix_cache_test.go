@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// syntheticTrace returns client addresses of skewed traffic: a few networks send most requests,
+// clients of a network use random addresses of its /24 prefix.
+func syntheticTrace(n int) []net.IP {
+	random := rand.New(rand.NewSource(1))
+	networks := rand.NewZipf(random, 1.2, 1, 4095)
+
+	trace := make([]net.IP, n)
+	for i := range trace {
+		network := networks.Uint64()
+		trace[i] = net.IPv4(10, byte(network>>8), byte(network), byte(random.Intn(256)))
+	}
+	return trace
+}
+
+func BenchmarkCacheHitRate(b *testing.B) {
+	trace := syntheticTrace(100000)
+	country := &geoip2.Country{}
+
+	b.Run("per-ip", func(b *testing.B) {
+		hits := 0
+		for i := 0; i < b.N; i++ {
+			cache := make(map[string]*geoip2.Country, defaultPrefixCacheSize)
+			hits = 0
+			for _, ip := range trace {
+				if _, ok := cache[string(ip)]; ok {
+					hits++
+					continue
+				}
+				cache[string(ip)] = country
+			}
+		}
+		b.ReportMetric(float64(hits)/float64(len(trace)), "hit-ratio")
+	})
+
+	b.Run("prefix", func(b *testing.B) {
+		hits := 0
+		for i := 0; i < b.N; i++ {
+			cache := newPrefixCache(defaultPrefixCacheSize)
+			hits = 0
+			for _, ip := range trace {
+				if _, ok := cache.get(ip, time.Time{}); ok {
+					hits++
+					continue
+				}
+				cache.put(ip, country, time.Time{})
+			}
+		}
+		b.ReportMetric(float64(hits)/float64(len(trace)), "hit-ratio")
+	})
+}
+
+func TestPrefixCacheWithBlockAnonymous(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithBlockAnonymous(),
+		WithPrefixCache(),
+	)
+
+	if p.cache != nil {
+		t.Error("prefix cache is used while anonymous proxies are blocked")
+	}
+}
+
+func TestLookupCacheTTL(t *testing.T) {
+	clock := newFakeClock()
+	var lock sync.Mutex
+	codes := map[string]string{"1.1.1.1": "US"}
+	lookups := 0
+	p := openTestProxy(t,
+		WithClock(clock),
+		WithResolver(func(ip net.IP) (*geoip2.Country, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			lookups++
+			return countries(codes)(ip)
+		}),
+		WithAllowedCountries([]string{"US"}),
+		WithLookupCacheTTL(time.Minute),
+	)
+	handler := p.Middleware()(okHandler)
+
+	status := func() int {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest("1.1.1.1"))
+		return res.Code
+	}
+
+	if code := status(); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, code)
+	}
+
+	// the country of the address changes, the cached entry is used until it expires
+	lock.Lock()
+	codes["1.1.1.1"] = "DE"
+	lock.Unlock()
+
+	clock.Advance(time.Minute - time.Second)
+	if code := status(); code != http.StatusOK {
+		t.Errorf("expected the cached country to be used before the TTL elapses, got %d", code)
+	}
+
+	clock.Advance(time.Second)
+	if code := status(); code != http.StatusForbidden {
+		t.Errorf("expected the address to be re-resolved after the TTL elapses, got %d", code)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if lookups != 2 {
+		t.Errorf("expected 2 lookups, got %d", lookups)
+	}
+}
@@ -1,34 +1,152 @@
 package proxy
 
 import (
+	"context"
+	"crypto/x509"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/oschwald/geoip2-golang"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 const geoHeaderName = "X-Geo-Country"
 
-type filterFunc func(string) bool
+// dirPollInterval is an interval of checking whether a removed database directory has reappeared.
+const dirPollInterval = time.Second
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultWriteTimeout      = 60 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+)
+
+type filterFunc func(countryInfo) bool
 type actionFunc func(res http.ResponseWriter, req *http.Request)
 type resolveCityFunc func(ipAddress net.IP) (*geoip2.Country, error)
 
 type geoProxy struct {
-	port      uint
-	dbPath    string
-	targetUrl string
-	filter    filterFunc
-	action    actionFunc
-	resolve   resolveCityFunc
-	db        *geoip2.Reader
-	dbLock    *sync.RWMutex
-	logger    *zap.Logger
+	port                 uint
+	unixSocket           string
+	listeners            []additionalListener
+	clientCAs            *x509.CertPool
+	dbPath               string
+	ipv6DbPath           string
+	dbWaitTimeout        time.Duration
+	asnDbPath            string
+	targetUrl            string
+	lazyTarget           bool
+	strictTarget         bool
+	lookupHost           func(host string) ([]string, error)
+	filter               filterFunc
+	allowList            *allowList
+	networkFilter        networkFilterFunc
+	allowedNetworks      []*net.IPNet
+	cidrPrecedence       bool
+	asnFilter            asnFilterFunc
+	ptrFilter            *ptrFilter
+	filterLock           *sync.RWMutex
+	methodRules          map[string]methodRule
+	remoteRules          *remoteRulesSource
+	schedules            map[string]countrySchedule
+	countrySource        CountrySource
+	countryMapper        func(string) string
+	blockAnonymous       bool
+	blockHosting         bool
+	blockEmptyUserAgent  bool
+	blockedHeaders       []headerRule
+	allowedMethods       map[string]bool
+	allowHeader          string
+	actions              []actionFunc
+	corsOrigins          map[string]bool
+	softBlockHeader      string
+	blockResponse        string
+	ignoredCountries     []string
+	honeypotUrl          string
+	blockBackendUrl      string
+	noContentBlock       bool
+	blockPage            *remoteBlockPage
+	resolve              resolveCityFunc
+	customResolver       bool
+	lookupTimeout        time.Duration
+	serverTiming         bool
+	cache                *prefixCache
+	cacheTTL             time.Duration
+	warmupPath           string
+	badAddrAsUnresolved  bool
+	badRequestStatus     int
+	badRequestBody       string
+	timeouts             serverTimeouts
+	maxHeaderBytes       int
+	clock                Clock
+	stats                *counters
+	statsd               *statsdClient
+	mux                  *http.ServeMux
+	retries              int
+	retryBodySize        int64
+	transport            http.RoundTripper
+	baseTransport        http.RoundTripper
+	breaker              *circuitBreaker
+	overload             *overloadDetector
+	db                   *geoip2.Reader
+	ipv6Db               *geoip2.Reader
+	asnDb                *geoip2.Reader
+	dbLock               *sync.RWMutex
+	reloads              *reloadGroup
+	dbLocked             bool
+	autoReload           bool
+	watcher              *fsnotify.Watcher
+	stopWatcher          chan struct{}
+	watcherStopped       chan struct{}
+	logger               *zap.Logger
+	requestLogger        *zap.Logger
+	sampling             logSampling
+	quiet                bool
+	anonymizeIP          bool
+	logFile              *lumberjack.Logger
+	accessLog            *accessLog
+	selfTest             map[string]string
+	rewriteRedirects     bool
+	viaHeader            bool
+	requestModifiers     []func(*http.Request)
+	lookupAPIPath        string
+	lookupLimiter        *tokenLimiter
+	countryLimiters      map[string]*tokenLimiter
+	xffSelector          *xffSelector
+	trustedProxies       []*net.IPNet
+	decisionStreamPath   string
+	decisionStream       *decisionBroadcast
+	reverseProxy         *httputil.ReverseProxy
+	weightedTargets      []*weightedTarget
+	totalWeight          int
+	randomIntn           func(n int) int
+	tarpit               *tarpit
+	redirectPreservePath bool
+	redirectStatus       int
+	geoJSONHeader        string
+	countryHeaders       []string
+	injectGeoHeader      bool
+	version              *VersionInfo
+	versionPath          string
+	adminAddr            string
+}
+
+type serverTimeouts struct {
+	readHeader time.Duration
+	read       time.Duration
+	write      time.Duration
+	idle       time.Duration
 }
 
 // StartOption defines functions used to configure a proxy server
@@ -43,9 +161,10 @@ func WithMessage(message string) StartOption {
 	return func(proxy *geoProxy) (*geoProxy, error) {
 		const tmpl = `<!DOCTYPE html><html><head><meta charset="utf-8"></head><body>%s</body></html>`
 		responseData := []byte(fmt.Sprintf(tmpl, message))
-		proxy.action = func(res http.ResponseWriter, req *http.Request) {
+		proxy.blockResponse = "message"
+		proxy.addAction(func(res http.ResponseWriter, req *http.Request) {
 			_, _ = res.Write(responseData)
-		}
+		})
 
 		return proxy, nil
 	}
@@ -54,9 +173,10 @@ func WithMessage(message string) StartOption {
 // WithFile is used to configure a proxy to make it return a file content when request is blocked.
 func WithFile(filePath string) StartOption {
 	return func(proxy *geoProxy) (*geoProxy, error) {
-		proxy.action = func(res http.ResponseWriter, req *http.Request) {
+		proxy.blockResponse = "file"
+		proxy.addAction(func(res http.ResponseWriter, req *http.Request) {
 			http.ServeFile(res, req, filePath)
-		}
+		})
 		return proxy, nil
 	}
 }
@@ -64,11 +184,77 @@ func WithFile(filePath string) StartOption {
 // WithAutoReload is used to configure a proxy to automatically reload when GeoIP database is updated.
 func WithAutoReload() StartOption {
 	return func(proxy *geoProxy) (*geoProxy, error) {
-		if err := proxy.startWatchingDb(); err != nil {
-			return nil, err
+		proxy.autoReload = true
+		proxy.enableDbLock()
+		return proxy, nil
+	}
+}
+
+// WithLookupTimeout is used to limit the time spent on a GeoIP database lookup.
+// A lookup that does not complete in time is treated as unresolved.
+// A resolver can not be interrupted, so a timed out lookup keeps running in its own goroutine
+// until the resolver returns: at most one goroutine is left per request whose lookup has timed out.
+func WithLookupTimeout(timeout time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if timeout <= 0 {
+			return nil, configError(ErrInvalidOption, "lookup timeout must be positive")
+		}
+
+		proxy.lookupTimeout = timeout
+		return proxy, nil
+	}
+}
+
+// WithBadAddrAsUnresolved is used to handle requests with unparseable client addresses
+// like requests with unresolved countries instead of responding with 400 Bad Request.
+func WithBadAddrAsUnresolved() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.badAddrAsUnresolved = true
+		return proxy, nil
+	}
+}
+
+// WithBadRequestResponse is used to respond to requests with unparseable client addresses
+// with the specified status and body instead of an empty 400 Bad Request.
+func WithBadRequestResponse(status int, body string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if status < 100 || status > 599 {
+			return nil, configError(ErrInvalidOption, "invalid bad request status %d", status)
+		}
+
+		proxy.badRequestStatus = status
+		proxy.badRequestBody = body
+		return proxy, nil
+	}
+}
+
+// WithServerTimeouts is used to override the timeouts of a proxy server.
+// A zero value disables the corresponding timeout.
+func WithServerTimeouts(readHeader, read, write, idle time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if readHeader < 0 || read < 0 || write < 0 || idle < 0 {
+			return nil, configError(ErrInvalidOption, "server timeouts must not be negative")
+		}
+
+		proxy.timeouts = serverTimeouts{
+			readHeader: readHeader,
+			read:       read,
+			write:      write,
+			idle:       idle,
+		}
+		return proxy, nil
+	}
+}
+
+// WithMaxHeaderBytes is used to limit the size of request headers, requests with larger headers
+// are rejected with 431 Request Header Fields Too Large. http.DefaultMaxHeaderBytes is used by default.
+func WithMaxHeaderBytes(size int) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if size <= 0 {
+			return nil, configError(ErrInvalidOption, "maximum header size must be positive")
 		}
 
-		proxy.resolve = proxy.resolveIpWithLock
+		proxy.maxHeaderBytes = size
 		return proxy, nil
 	}
 }
@@ -76,10 +262,53 @@ func WithAutoReload() StartOption {
 // WithRedirect is used to configure a proxy to redirect a client to the specified URL when request is blocked.
 func WithRedirect(redirectUrl string) StartOption {
 	return func(proxy *geoProxy) (*geoProxy, error) {
-		proxy.action = func(res http.ResponseWriter, req *http.Request) {
-			http.Redirect(res, req, redirectUrl, http.StatusTemporaryRedirect)
+		if _, err := url.Parse(redirectUrl); err != nil {
+			return nil, configError(ErrInvalidOption, "invalid redirect URL '%s'", redirectUrl)
 		}
 
+		proxy.blockResponse = "redirect"
+		proxy.addAction(func(res http.ResponseWriter, req *http.Request) {
+			location := redirectUrl
+			if proxy.redirectPreservePath {
+				location = appendRequestPath(redirectUrl, req.URL)
+			}
+			http.Redirect(res, req, location, proxy.redirectStatus)
+		})
+
+		return proxy, nil
+	}
+}
+
+// WithRedirectStatus is used together with WithRedirect to change the redirect status code.
+// It must be one of 301, 302, 303, 307 or 308, 307 is used by default.
+func WithRedirectStatus(status int) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		switch status {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+			proxy.redirectStatus = status
+		default:
+			return nil, configError(ErrInvalidOption, "invalid redirect status code %d", status)
+		}
+
+		return proxy, nil
+	}
+}
+
+// WithRedirectPreservePath is used together with WithRedirect to append the path and the query
+// of a blocked request to the redirect URL.
+func WithRedirectPreservePath() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.redirectPreservePath = true
+		return proxy, nil
+	}
+}
+
+// WithIgnoredCountries is used to report country names which are not recognized and left out of the rules
+// by the caller, a warning listing them is logged when the proxy is opened.
+func WithIgnoredCountries(names []string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.ignoredCountries = append(proxy.ignoredCountries, names...)
 		return proxy, nil
 	}
 }
@@ -88,30 +317,35 @@ func WithRedirect(redirectUrl string) StartOption {
 // It acts as a no-op and does not block any requests.
 func WithNoFilter() StartOption {
 	return func(proxy *geoProxy) (*geoProxy, error) {
-		proxy.filter = func(string) bool {
+		proxy.filter = func(countryInfo) bool {
 			return true
 		}
+		proxy.allowList = nil
 
 		return proxy, nil
 	}
 }
 
 // WithAllowedCountries is used to configure a proxy to allow requests coming form a list of specified countries.
-// All other requests will be blocked.
+// All other requests will be blocked. Repeated allow options, including WithAllowEUOnly, accumulate:
+// a request is allowed when its country is allowed by any of them. Any other country filtering
+// option replaces the accumulated list.
 func WithAllowedCountries(countries []string) StartOption {
 	return func(proxy *geoProxy) (*geoProxy, error) {
 		if len(countries) == 0 {
-			return nil, errors.New("allowed countries are not specified")
+			return nil, configError(ErrNoRule, "allowed countries are not specified")
 		}
 
-		allowedCountries := make(map[string]bool)
-		for _, c := range countries {
-			allowedCountries[c] = true
+		countries, err := normalizeCountries(countries)
+		if err != nil {
+			return nil, err
 		}
 
-		proxy.filter = func(c string) bool {
-			return allowedCountries[c]
+		list := proxy.allowList.extend()
+		for _, c := range countries {
+			list.countries[c] = true
 		}
+		proxy.setAllowList(list)
 
 		return proxy, nil
 	}
@@ -122,7 +356,12 @@ func WithAllowedCountries(countries []string) StartOption {
 func WithBlockedCountries(countries []string) StartOption {
 	return func(proxy *geoProxy) (*geoProxy, error) {
 		if len(countries) == 0 {
-			return nil, errors.New("blocked countries are not specified")
+			return nil, configError(ErrNoRule, "blocked countries are not specified")
+		}
+
+		countries, err := normalizeCountries(countries)
+		if err != nil {
+			return nil, err
 		}
 
 		blockedCountries := make(map[string]bool)
@@ -130,9 +369,35 @@ func WithBlockedCountries(countries []string) StartOption {
 			blockedCountries[c] = true
 		}
 
-		proxy.filter = func(c string) bool {
-			return !blockedCountries[c]
+		proxy.filter = func(c countryInfo) bool {
+			return !blockedCountries[c.isoCode]
+		}
+		proxy.allowList = nil
+
+		return proxy, nil
+	}
+}
+
+// WithAllowEUOnly is used to configure a proxy to allow only requests coming from the European Union member states.
+// All other requests will be blocked. The option accumulates with WithAllowedCountries.
+func WithAllowEUOnly() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		list := proxy.allowList.extend()
+		list.eu = true
+		proxy.setAllowList(list)
+
+		return proxy, nil
+	}
+}
+
+// WithBlockEU is used to configure a proxy to block requests coming from the European Union member states.
+// All other requests will be allowed.
+func WithBlockEU() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.filter = func(c countryInfo) bool {
+			return !c.inEU
 		}
+		proxy.allowList = nil
 
 		return proxy, nil
 	}
@@ -140,15 +405,39 @@ func WithBlockedCountries(countries []string) StartOption {
 
 // New is used to create a new instance of geoProxy
 func New(port uint, database string, target string, opts ...StartOption) (*geoProxy, error) {
+	if len(target) > 0 {
+		if err := validateTarget(target); err != nil {
+			return nil, err
+		}
+	}
+
 	proxy := &geoProxy{
-		port:      port,
-		dbPath:    database,
-		targetUrl: target,
-		action:    defaultAction,
-		dbLock:    new(sync.RWMutex),
+		port:             port,
+		dbPath:           database,
+		targetUrl:        target,
+		dbLock:           new(sync.RWMutex),
+		filterLock:       new(sync.RWMutex),
+		reloads:          new(reloadGroup),
+		mux:              http.NewServeMux(),
+		redirectStatus:   http.StatusTemporaryRedirect,
+		badRequestStatus: http.StatusBadRequest,
+		clock:            realClock{},
+		lookupHost:       net.LookupHost,
+		randomIntn:       rand.Intn,
+		stats:            new(counters),
+		countryHeaders:   []string{geoHeaderName},
+		injectGeoHeader:  true,
+		sampling:         logSampling{initial: defaultSamplingInitial, thereafter: defaultSamplingThereafter},
+		timeouts: serverTimeouts{
+			readHeader: defaultReadHeaderTimeout,
+			read:       defaultReadTimeout,
+			write:      defaultWriteTimeout,
+			idle:       defaultIdleTimeout,
+		},
 	}
 
 	proxy.resolve = proxy.resolveIp
+	_, _ = WithNoFilter()(proxy)
 
 	for _, opt := range opts {
 		_, err := opt(proxy)
@@ -157,11 +446,34 @@ func New(port uint, database string, target string, opts ...StartOption) (*geoPr
 		}
 	}
 
+	if proxy.noContentBlock && len(proxy.blockResponse) > 0 {
+		return nil, configError(ErrInvalidOption, "no content block can not be combined with a %s block response", proxy.blockResponse)
+	}
+
+	if len(proxy.blockBackendUrl) > 0 && len(proxy.honeypotUrl) > 0 {
+		return nil, configError(ErrInvalidOption, "block backend can not be combined with a honeypot target")
+	}
+
+	if proxy.tarpit != nil && proxy.timeouts.write > 0 && proxy.tarpit.delay >= proxy.timeouts.write {
+		return nil, configError(ErrInvalidOption, "tarpit delay %s must be shorter than the server write timeout %s",
+			proxy.tarpit.delay, proxy.timeouts.write)
+	}
+
+	if proxy.decisionStream != nil && len(proxy.adminAddr) == 0 {
+		return nil, configError(ErrInvalidOption, "decision stream requires an admin listener")
+	}
+
 	return proxy, nil
 }
 
+// OpenDatabase opens a GeoIP database the same way the proxy does, gzipped databases are supported.
+func OpenDatabase(path string) (*geoip2.Reader, error) {
+	return loadGeoDb(path)
+}
+
+// loadGeoDb loads a database from a .mmdb file or a gzip-compressed .mmdb.gz file.
 func loadGeoDb(path string) (*geoip2.Reader, error) {
-	db, err := geoip2.Open(path)
+	db, err := openGeoDb(path)
 	if err != nil {
 		var reason string
 		if os.IsNotExist(err) {
@@ -169,99 +481,388 @@ func loadGeoDb(path string) (*geoip2.Reader, error) {
 		} else {
 			reason = fmt.Sprintf("failed to open '%s' file", path)
 		}
-		return nil, errors.Errorf("Can not load GeoLite database, %s\n", reason)
+		return nil, configError(ErrDatabase, "Can not load GeoLite database, %s\n", reason)
 	}
 
 	return db, nil
 }
 
+// reloadGeoDb reloads databases from their files, concurrent reloads are coalesced.
 func (p *geoProxy) reloadGeoDb() error {
-	newDb, err := loadGeoDb(p.dbPath)
+	return p.reloads.do(p.replaceDatabases)
+}
+
+func (p *geoProxy) replaceDatabases() error {
+	newDb, newIPv6Db, err := p.loadDatabases()
 	if err != nil {
 		return err
 	}
 
-	var oldDb *geoip2.Reader
+	var oldDb, oldIPv6Db *geoip2.Reader
 
 	p.dbLock.Lock()
-	oldDb = p.db
-	p.db = newDb
+	oldDb, oldIPv6Db = p.db, p.ipv6Db
+	p.db, p.ipv6Db = newDb, newIPv6Db
 	p.dbLock.Unlock()
 
+	p.afterDbReload()
+
+	if oldIPv6Db != nil {
+		_ = oldIPv6Db.Close()
+	}
+
+	// no database is loaded at startup when a custom resolver is used
+	if oldDb == nil {
+		return nil
+	}
+
 	return oldDb.Close()
 }
 
 func (p *geoProxy) resolveIp(ip net.IP) (*geoip2.Country, error) {
-	return p.db.Country(ip)
+	return p.dbFor(ip).Country(ip)
 }
 
 func (p *geoProxy) resolveIpWithLock(ip net.IP) (*geoip2.Country, error) {
 	p.dbLock.RLock()
-	defer p.dbLock.Unlock()
+	defer p.dbLock.RUnlock()
 
 	return p.resolveIp(ip)
 }
 
-func (p *geoProxy) errorHandler(rw http.ResponseWriter, _ *http.Request, err error) {
-	p.logger.Warn("proxy error",
+type lookupResult struct {
+	country *geoip2.Country
+	err     error
+}
+
+func (p *geoProxy) lookup(ctx context.Context, ip net.IP) (*geoip2.Country, error) {
+	if p.cache == nil {
+		return p.lookupDb(ctx, ip)
+	}
+
+	if country, ok := p.cache.get(ip, p.clock.Now()); ok {
+		return country, nil
+	}
+
+	country, err := p.lookupDb(ctx, ip)
+	if err == nil {
+		p.cache.put(ip, country, p.cacheExpiry())
+	}
+	return country, err
+}
+
+func (p *geoProxy) lookupDb(ctx context.Context, ip net.IP) (*geoip2.Country, error) {
+	if p.lookupTimeout == 0 {
+		return p.resolve(ip)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.lookupTimeout)
+	defer cancel()
+
+	resolve := p.resolve
+	done := make(chan lookupResult, 1)
+	go func() {
+		country, err := resolve(ip)
+		done <- lookupResult{country, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.country, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *geoProxy) errorHandler(rw http.ResponseWriter, req *http.Request, err error) {
+	// the request context is canceled when a client disconnects, the canceled target request is expected
+	if req.Context().Err() == context.Canceled {
+		p.requestLogger.Debug("request is canceled by client",
+			zap.String("error", err.Error()),
+		)
+		rw.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	p.requestLogger.Warn("proxy error",
 		zap.String("error", err.Error()),
 	)
-	rw.WriteHeader(http.StatusBadGateway)
+	rw.WriteHeader(p.targetErrorStatus(err))
 }
 
-func (p *geoProxy) getRequestHandler() func(http.ResponseWriter, *http.Request) {
-	return func(res http.ResponseWriter, req *http.Request) {
-		addr := getRemoteAddr(req)
-		ip := getIP(addr)
+func (p *geoProxy) block(res http.ResponseWriter, req *http.Request) {
+	if p.tarpit != nil {
+		p.tarpit.wait(req.Context(), p.clock)
+	}
 
-		if ip == nil {
-			p.logger.Info("can't get IP address for request",
-				zap.String("addr", addr),
-			)
-			res.WriteHeader(http.StatusBadRequest)
-			return
+	p.setPreflightCORS(res, req)
+	p.runActions(res, req)
+}
+
+// deny blocks a request, or tags and passes it further when soft blocking is enabled.
+func (p *geoProxy) deny(res http.ResponseWriter, req *http.Request) (*http.Request, bool) {
+	p.countRequest(&p.stats.blocked, "blocked")
+
+	if len(p.softBlockHeader) == 0 {
+		p.block(res, req)
+		return req, false
+	}
+
+	req.Header.Set(p.softBlockHeader, "true")
+	if decision, ok := DecisionFromContext(req.Context()); ok && len(decision.Country) > 0 && p.injectGeoHeader {
+		p.setCountryHeaders(req.Header, decision.Country)
+	}
+
+	return req, true
+}
+
+// filterRequest applies filtering rules to a request.
+// It returns the request to pass further and true when the request is allowed,
+// otherwise a response is already written.
+func (p *geoProxy) filterRequest(res http.ResponseWriter, req *http.Request) (*http.Request, bool) {
+	if len(p.softBlockHeader) > 0 {
+		req.Header.Del(p.softBlockHeader)
+	}
+	p.stripGeoHeaders(req.Header)
+
+	if p.rejectUnauthenticated(res, req) {
+		return req, false
+	}
+
+	if p.rejectMethod(res, req) {
+		return req, false
+	}
+
+	addr := p.clientAddr(req)
+	ip := getIP(addr)
+
+	if ip == nil && p.badAddrAsUnresolved {
+		p.requestLogger.Debug("can't get IP address for request, treating it as unresolved",
+			p.addrField(addr),
+		)
+		p.countRequest(&p.stats.unresolved, "unresolved")
+		return p.deny(res, withDecision(req, Decision{}))
+	}
+
+	if ip == nil {
+		p.requestLogger.Info("can't get IP address for request",
+			p.addrField(addr),
+		)
+		p.countRequest(&p.stats.invalid, "invalid")
+		p.writeBadRequest(res)
+		return req, false
+	}
+
+	if reason := p.blockedUserAgent(req); reason != nil {
+		p.logBlock(ip, reason)
+		return p.deny(res, withDecision(req, Decision{}))
+	}
+
+	if reason := p.blockedHeader(req); reason != nil {
+		p.logBlock(ip, reason)
+		return p.deny(res, withDecision(req, Decision{}))
+	}
+
+	rules := p.filters(req.Method)
+
+	if p.cidrPrecedence && rules.allowedNetworks != nil {
+		if containsIP(rules.allowedNetworks, ip) {
+			return p.allowNetwork(req, ip)
+		}
+	} else if rules.networkFilter != nil {
+		if reason := rules.networkFilter(ip); reason != nil {
+			p.logBlock(ip, reason)
+			return p.deny(res, withDecision(req, Decision{}))
 		}
+	}
 
-		country, err := p.resolve(ip)
-		if err != nil {
-			p.logger.Info("can't find a country by ip",
-				zap.String("ip", ip.String()),
+	if p.ptrFilter != nil {
+		if name := p.blockedPTR(req.Context(), ip); len(name) > 0 {
+			p.logBlock(ip, &blockReason{rule: "PTR", value: name})
+			return p.deny(res, withDecision(req, Decision{}))
+		}
+	}
+
+	if reason := p.blockedASN(ip); reason != nil {
+		p.logBlock(ip, reason)
+		return p.deny(res, withDecision(req, Decision{}))
+	}
+
+	lookupStart := p.clock.Now()
+	country, err := p.lookup(req.Context(), ip)
+	lookupDuration := p.clock.Now().Sub(lookupStart)
+	if p.serverTiming {
+		setServerTiming(res, lookupDuration)
+	}
+	if p.statsd != nil {
+		p.statsd.timing("lookup", lookupDuration)
+	}
+	if err == context.Canceled {
+		// the client has gone away, there is nobody to respond to
+		p.requestLogger.Debug("country lookup canceled by client",
+			p.ipField(ip),
+		)
+		return withDecision(req, Decision{}), false
+	}
+	if err != nil {
+		p.countRequest(&p.stats.unresolved, "unresolved")
+		if err == context.DeadlineExceeded {
+			p.requestLogger.Warn("country lookup timed out",
+				p.ipField(ip),
 			)
-			p.action(res, req)
-			return
+		} else {
+			p.requestLogger.Info("can't find a country by ip",
+				p.ipField(ip),
+			)
+		}
+		return p.deny(res, withDecision(req, Decision{}))
+	}
+
+	info := p.selectCountry(country)
+	if trait := p.blockedTrait(ip, country); trait != "" {
+		req = withDecision(req, Decision{Country: info.isoCode})
+		p.logBlock(ip, &blockReason{rule: "trait", value: trait})
+		return p.deny(res, req)
+	}
+
+	var reason *blockReason
+	if !rules.filter(info) {
+		reason = &blockReason{rule: "country", value: info.isoCode}
+	} else if !p.scheduleAllows(info.isoCode) {
+		reason = &blockReason{rule: "schedule", value: info.isoCode}
+	}
+
+	req = withDecision(req, Decision{Allowed: reason == nil, Country: info.isoCode})
+	if reason != nil {
+		p.logBlock(ip, reason, zap.String("country", info.names["en"]))
+		return p.deny(res, req)
+	}
+
+	if p.rateLimited(res, info.isoCode) {
+		return withDecision(req, Decision{Country: info.isoCode}), false
+	}
+
+	if p.injectGeoHeader {
+		p.setCountryHeaders(req.Header, info.isoCode)
+		if len(p.geoJSONHeader) > 0 {
+			p.setGeoJSONHeader(req, ip, country, info)
 		}
+	}
+
+	p.countRequest(&p.stats.allowed, "allowed")
+	return req, true
+}
+
+func (p *geoProxy) writeBadRequest(res http.ResponseWriter) {
+	if len(p.badRequestBody) == 0 {
+		res.WriteHeader(p.badRequestStatus)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(p.badRequestStatus)
+	_, _ = res.Write([]byte(p.badRequestBody))
+}
 
-		allowed := p.filter(country.Country.IsoCode)
+// Handler returns a handler which filters requests and proxies allowed ones to the target.
+// Open must be called before the handler serves requests.
+func (p *geoProxy) Handler() http.Handler {
+	handler := http.HandlerFunc(p.getRequestHandler())
+	if p.accessLog != nil {
+		return p.withAccessLog(handler)
+	}
+
+	return handler
+}
+
+func (p *geoProxy) getRequestHandler() func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+		req, allowed := p.filterRequest(res, req)
+		if p.decisionStream != nil {
+			p.publishDecision(req)
+		}
 		if !allowed {
-			p.logger.Info("forbidden country",
-				zap.String("ip", ip.String()),
-				zap.String("country", country.Country.Names["en"]),
-			)
-			p.action(res, req)
 			return
 		}
 
-		req.Header.Set(geoHeaderName, country.Country.IsoCode)
+		p.serveReverseProxy(res, req)
+	}
+}
+
+// dbWatchDirs returns directories to watch for database changes.
+// When the database path is a symlink, the directory of the resolved file is watched as well.
+func (p *geoProxy) dbWatchDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+
+	for _, path := range p.dbPaths() {
+		candidates := []string{filepath.Dir(path)}
+		if realPath, err := filepath.EvalSymlinks(path); err == nil {
+			candidates = append(candidates, filepath.Dir(realPath))
+		}
+
+		for _, dir := range candidates {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	return dirs
+}
+
+func (p *geoProxy) isDbFile(name string) bool {
+	name = filepath.Clean(name)
+	for _, path := range p.dbPaths() {
+		if name == filepath.Clean(path) {
+			return true
+		}
 
-		serveReverseProxy(p.targetUrl, res, req, p.errorHandler)
+		if realPath, err := filepath.EvalSymlinks(path); err == nil && name == realPath {
+			return true
+		}
 	}
+
+	return false
 }
 
 func (p *geoProxy) setupDbWatcher(wg *sync.WaitGroup) error {
+	defer close(p.watcherStopped)
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
+		wg.Done()
 		return err
 	}
 	defer func() {
 		_ = watcher.Close()
 	}()
+	p.watcher = watcher
 
 	watcherWG := sync.WaitGroup{}
 	watcherWG.Add(1)
 
+	dirs := p.dbWatchDirs()
+	watchedDirs := make(map[string]bool)
+	for _, dir := range dirs {
+		watchedDirs[dir] = true
+	}
+	rewatching := make(map[string]bool)
+	rewatched := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+
 	go func() {
 		for {
 			select {
+			case <-p.stopWatcher:
+				watcherWG.Done()
+				return
+
+			case dir := <-rewatched:
+				delete(rewatching, dir)
+
 			case event, more := <-watcher.Events:
 				if !more {
 					watcherWG.Done()
@@ -269,9 +870,22 @@ func (p *geoProxy) setupDbWatcher(wg *sync.WaitGroup) error {
 					return
 				}
 
-				realPath, _ := filepath.EvalSymlinks(p.dbPath)
+				// a watch is dropped when its directory is removed, e.g. on a remount
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					if dir := removedDir(event.Name, watchedDirs); len(dir) > 0 {
+						if !rewatching[dir] {
+							rewatching[dir] = true
+							p.logger.Warn("watched directory is removed, waiting for it to reappear",
+								zap.String("dir", dir),
+							)
+							go p.rewatchDir(watcher, dir, rewatched, done)
+						}
+						continue
+					}
+				}
+
 				const writeOrCreateMask = fsnotify.Write | fsnotify.Create
-				if filepath.Clean(event.Name) == realPath && event.Op&writeOrCreateMask != 0 {
+				if p.isDbFile(event.Name) && event.Op&writeOrCreateMask != 0 {
 					err := p.reloadGeoDb()
 					if err != nil {
 						p.logger.Error("failed to reload Geo DB",
@@ -294,10 +908,11 @@ func (p *geoProxy) setupDbWatcher(wg *sync.WaitGroup) error {
 		}
 	}()
 
-	dir := filepath.Dir(p.dbPath)
-	err = watcher.Add(dir)
-	if err != nil {
-		return err
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			wg.Done()
+			return err
+		}
 	}
 
 	wg.Done()
@@ -306,7 +921,65 @@ func (p *geoProxy) setupDbWatcher(wg *sync.WaitGroup) error {
 	return nil
 }
 
+// removedDir returns the watched directory of a removed path when the directory does not exist anymore.
+// The removal of a directory itself is not reported while a database in it is still open,
+// so removals of files in watched directories are checked as well.
+func removedDir(name string, watchedDirs map[string]bool) string {
+	for _, dir := range []string{name, filepath.Dir(name)} {
+		if !watchedDirs[dir] {
+			continue
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return dir
+		}
+	}
+
+	return ""
+}
+
+// rewatchDir waits for a removed directory to reappear, watches it again and reloads databases,
+// since they have likely been replaced while the directory was missing. The directory is sent
+// to rewatched once it is watched again.
+func (p *geoProxy) rewatchDir(watcher *fsnotify.Watcher, dir string, rewatched chan<- string, done <-chan struct{}) {
+	for {
+		select {
+		case <-p.clock.After(dirPollInterval):
+		case <-done:
+			return
+		}
+
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+
+		if err := watcher.Add(dir); err != nil {
+			continue
+		}
+
+		p.logger.Info("watched directory has reappeared",
+			zap.String("dir", dir),
+		)
+
+		if err := p.reloadGeoDb(); err != nil {
+			p.logger.Error("failed to reload Geo DB",
+				zap.Error(err),
+			)
+		} else {
+			p.logger.Info("Geo DB is reloaded")
+		}
+
+		select {
+		case rewatched <- dir:
+		case <-done:
+		}
+		return
+	}
+}
+
 func (p *geoProxy) startWatchingDb() error {
+	p.stopWatcher = make(chan struct{})
+	p.watcherStopped = make(chan struct{})
+
 	setupWG := sync.WaitGroup{}
 	setupWG.Add(1)
 
@@ -320,34 +993,193 @@ func (p *geoProxy) startWatchingDb() error {
 	return err
 }
 
+// stopWatchingDb stops the file watcher and the pollers of removed directories.
+func (p *geoProxy) stopWatchingDb() {
+	if p.stopWatcher == nil {
+		return
+	}
+
+	close(p.stopWatcher)
+	<-p.watcherStopped
+	p.stopWatcher = nil
+}
+
+// Open loads a GeoIP database and prepares a proxy to handle requests.
+// It is called by Start, use it directly when the proxy is used as a middleware.
+func (p *geoProxy) Open() error {
+	if err := p.setupLoggers(); err != nil {
+		return err
+	}
+
+	if len(p.ignoredCountries) > 0 {
+		p.logger.Warn("unknown country names are ignored",
+			zap.Strings("countries", p.ignoredCountries),
+		)
+	}
+
+	if !p.customResolver {
+		db, ipv6Db, err := p.waitForDatabases()
+		if err != nil {
+			p.closeLoggers()
+			return err
+		}
+		p.db, p.ipv6Db = db, ipv6Db
+	}
+
+	if err := p.openASNDatabase(); err != nil {
+		_ = p.Close()
+		return err
+	}
+
+	if p.statsd != nil {
+		if err := p.statsd.open(); err != nil {
+			_ = p.Close()
+			return err
+		}
+	}
+
+	if len(p.selfTest) > 0 {
+		if err := p.runSelfTest(); err != nil {
+			_ = p.Close()
+			return err
+		}
+	}
+
+	p.checkTraitsSupport()
+	p.checkCacheSupport()
+	p.warmUpCache()
+
+	if err := p.checkTarget(); err != nil {
+		_ = p.Close()
+		return err
+	}
+	p.startBlockPage()
+	p.startRemoteRules()
+
+	if p.autoReload {
+		if err := p.startWatchingDb(); err != nil {
+			_ = p.Close()
+			return err
+		}
+	}
+
+	p.transport = http.DefaultTransport
+	if p.baseTransport != nil {
+		p.transport = p.baseTransport
+	}
+	if p.retries > 0 {
+		p.transport = &retryTransport{
+			next:        p.transport,
+			retries:     p.retries,
+			backoff:     retryBackoff,
+			clock:       p.clock,
+			maxBodySize: p.retryBodySize,
+		}
+	}
+	if p.breaker != nil {
+		p.transport = &breakerTransport{
+			next:    p.transport,
+			breaker: p.breaker,
+			clock:   p.clock,
+		}
+	}
+	p.reverseProxy = p.newReverseProxy(p.targetUrl)
+	for _, target := range p.weightedTargets {
+		target.proxy = p.newReverseProxy(target.url)
+	}
+
+	return nil
+}
+
+func (p *geoProxy) closeLoggers() {
+	_ = p.logger.Sync()
+	if p.logFile != nil {
+		_ = p.logFile.Close()
+	}
+}
+
+// Close releases resources acquired by Open
+func (p *geoProxy) Close() error {
+	p.stopWatchingDb()
+
+	err := p.closeDatabases()
+	if err != nil {
+		p.logger.Error("failed to close Geo DB")
+	}
+
+	if p.asnDb != nil {
+		_ = p.asnDb.Close()
+	}
+
+	p.stopBlockPage()
+	if p.statsd != nil {
+		p.statsd.close()
+	}
+	p.stopRemoteRules()
+	p.closeLoggers()
+
+	return err
+}
+
+// setupPublicEndpoints registers the handler of proxied requests or the lookup API on the main server.
+func (p *geoProxy) setupPublicEndpoints() {
+	if len(p.lookupAPIPath) > 0 {
+		handler := p.lookupAPIHandler
+		if p.accessLog != nil {
+			handler = p.withAccessLog(handler)
+		}
+		p.mux.HandleFunc(p.lookupAPIPath, handler)
+	} else {
+		p.mux.Handle("/", p.Handler())
+	}
+}
+
 // Start launches a proxy server
 func (p *geoProxy) Start() error {
-	logger, _ := zap.NewProduction()
+	if err := p.Open(); err != nil {
+		return err
+	}
 	defer func() {
-		_ = logger.Sync()
+		_ = p.Close()
 	}()
-	p.logger = logger
 
-	db, err := loadGeoDb(p.dbPath)
+	addr := p.listenAddr()
+	p.logger.Info("starting server",
+		append([]zap.Field{
+			zap.String("addr", addr),
+			zap.String("db", p.dbPath),
+		}, p.versionFields()...)...,
+	)
+
+	if adminServer := p.setupAdminEndpoints(); adminServer != nil {
+		if err := p.startAdminServer(adminServer); err != nil {
+			return err
+		}
+		defer func() {
+			_ = adminServer.Close()
+		}()
+	}
+
+	p.setupPublicEndpoints()
+
+	listener, err := p.listen()
 	if err != nil {
+		return errors.Errorf("Failed to start server: %v\n", err)
+	}
+
+	servers, err := p.startAdditionalListeners()
+	if err != nil {
+		_ = listener.Close()
 		return err
 	}
 	defer func() {
-		if err := p.db.Close(); err != nil {
-			p.logger.Error("failed to close Geo DB")
+		for _, server := range servers {
+			_ = server.Close()
 		}
 	}()
-	p.db = db
-
-	addr := fmt.Sprintf(":%d", p.port)
-	p.logger.Info("starting server",
-		zap.String("addr", addr),
-		zap.String("db", p.dbPath),
-	)
 
-	handler := p.getRequestHandler()
-	http.HandleFunc("/", handler)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	server := p.newServer(p.mux)
+	if err := server.Serve(listener); err != nil {
 		return errors.Errorf("Failed to start server: %v\n", err)
 	}
 
@@ -0,0 +1,332 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+	"github.com/pkg/errors"
+)
+
+// countries returns a resolver mapping client addresses to ISO country codes.
+func countries(codes map[string]string) func(net.IP) (*geoip2.Country, error) {
+	return func(ip net.IP) (*geoip2.Country, error) {
+		code, ok := codes[ip.String()]
+		if !ok {
+			return nil, errors.Errorf("address %s is not found", ip)
+		}
+
+		record := &geoip2.Country{}
+		record.Country.IsoCode = code
+		record.RegisteredCountry.IsoCode = code
+		return record, nil
+	}
+}
+
+func openTestProxy(t *testing.T, opts ...StartOption) *geoProxy {
+	t.Helper()
+
+	p, err := New(0, "", "", append([]StartOption{WithQuiet()}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = p.Close()
+	})
+
+	return p
+}
+
+// openLoggingProxy opens a proxy which writes logs to a file, the path of the file is returned.
+func openLoggingProxy(t *testing.T, opts ...StartOption) (*geoProxy, string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	path := filepath.Join(dir, "geofilter.log")
+
+	p, err := New(0, "", "", append([]StartOption{WithLogFile(path, 1, 0, 0)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = p.Close()
+	})
+
+	return p, path
+}
+
+// readLogs returns entries of a JSON log file.
+func readLogs(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid log line %s: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// findLogs returns log entries with the message.
+func findLogs(entries []map[string]interface{}, msg string) []map[string]interface{} {
+	var found []map[string]interface{}
+	for _, entry := range entries {
+		if entry["msg"] == msg {
+			found = append(found, entry)
+		}
+	}
+
+	return found
+}
+
+func newTestRequest(addr string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = net.JoinHostPort(addr, "1234")
+	return req
+}
+
+var okHandler = http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+	res.WriteHeader(http.StatusOK)
+})
+
+func TestLookupTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	blocking := func(net.IP) (*geoip2.Country, error) {
+		<-release
+		return nil, errors.New("released")
+	}
+
+	p := openTestProxy(t,
+		WithResolver(blocking),
+		WithLookupTimeout(10*time.Millisecond),
+		WithAllowedCountries([]string{"US"}),
+	)
+
+	res := httptest.NewRecorder()
+	started := time.Now()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.2.3.4"))
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("lookup was not aborted, took %s", elapsed)
+	}
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected the unresolved policy to block the request, got %d", res.Code)
+	}
+	if stats := p.Stats(); stats.Unresolved != 1 || stats.Blocked != 1 {
+		t.Errorf("expected an unresolved blocked request, got %+v", stats)
+	}
+}
+
+func TestLookupCanceledByClient(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	blocking := func(net.IP) (*geoip2.Country, error) {
+		<-release
+		return nil, errors.New("released")
+	}
+
+	actionCalled := false
+	p := openTestProxy(t,
+		WithResolver(blocking),
+		WithLookupTimeout(time.Minute),
+		WithActions(func(res http.ResponseWriter, _ *http.Request) {
+			actionCalled = true
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := newTestRequest("1.2.3.4").WithContext(ctx)
+
+	p.Middleware()(okHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if actionCalled {
+		t.Error("block action was called for a canceled request")
+	}
+	if stats := p.Stats(); stats.Unresolved != 0 || stats.Blocked != 0 || stats.Allowed != 0 {
+		t.Errorf("expected a canceled request not to be counted, got %+v", stats)
+	}
+}
+
+func TestCloseStopsWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := New(0, filepath.Join(dir, "country.mmdb"), "",
+		WithQuiet(),
+		WithResolver(countries(nil)),
+		WithAutoReload(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	stopped := p.watcherStopped
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("file watcher is running after the proxy is closed")
+	}
+}
+
+func TestIgnoredCountriesWarning(t *testing.T) {
+	_, path := openLoggingProxy(t,
+		WithResolver(countries(nil)),
+		WithAllowedCountries([]string{"US"}),
+		WithIgnoredCountries([]string{"Atlantis", "Narnia"}),
+	)
+
+	warnings := findLogs(readLogs(t, path), "unknown country names are ignored")
+	if len(warnings) != 1 {
+		t.Fatalf("expected a warning about ignored countries, got %v", warnings)
+	}
+	if names := fmt.Sprint(warnings[0]["countries"]); names != "[Atlantis Narnia]" {
+		t.Errorf("unexpected ignored countries %s", names)
+	}
+}
+
+func TestRedirectStatus(t *testing.T) {
+	resolver := WithResolver(countries(map[string]string{"1.1.1.1": "RU"}))
+	blocked := WithBlockedCountries([]string{"RU"})
+
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusFound, http.StatusPermanentRedirect} {
+		p := openTestProxy(t, resolver, blocked, WithRedirect("https://example.com/blocked"), WithRedirectStatus(status))
+
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+		if res.Code != status {
+			t.Errorf("expected %d, got %d", status, res.Code)
+		}
+		if location := res.Header().Get("Location"); location != "https://example.com/blocked" {
+			t.Errorf("%d: unexpected location '%s'", status, location)
+		}
+	}
+
+	p := openTestProxy(t, resolver, blocked, WithRedirect("https://example.com/blocked"))
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+	if res.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected 307 by default, got %d", res.Code)
+	}
+
+	for _, status := range []int{http.StatusOK, http.StatusNotModified, http.StatusForbidden, 0} {
+		if _, err := New(0, "", "", WithRedirectStatus(status)); err == nil {
+			t.Errorf("redirect status %d is accepted", status)
+		}
+	}
+}
+
+func TestMalformedRemoteAddr(t *testing.T) {
+	for _, addr := range []string{"", "garbage", "1.2.3:80", "[::1"} {
+		p := openTestProxy(t, WithResolver(countries(nil)), WithAllowedCountries([]string{"US"}))
+
+		req := newTestRequest("1.1.1.1")
+		req.RemoteAddr = addr
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, req)
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("'%s': expected 400, got %d", addr, res.Code)
+		}
+		if stats := p.Stats(); stats.Invalid != 1 {
+			t.Errorf("'%s': expected an invalid request, got %+v", addr, stats)
+		}
+	}
+
+	p := openTestProxy(t,
+		WithResolver(countries(nil)),
+		WithAllowedCountries([]string{"US"}),
+		WithBadAddrAsUnresolved(),
+	)
+
+	req := newTestRequest("1.1.1.1")
+	req.RemoteAddr = "garbage"
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, req)
+
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected the unresolved policy to block the request, got %d", res.Code)
+	}
+	if stats := p.Stats(); stats.Unresolved != 1 || stats.Invalid != 0 {
+		t.Errorf("expected an unresolved request, got %+v", stats)
+	}
+}
+
+func TestBadRequestResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []StartOption
+		status int
+		body   string
+	}{
+		{"default", nil, http.StatusBadRequest, ""},
+		{"custom", []StartOption{WithBadRequestResponse(http.StatusForbidden, "client address is unknown")},
+			http.StatusForbidden, "client address is unknown"},
+	}
+
+	for _, test := range tests {
+		p := openTestProxy(t, append([]StartOption{WithResolver(countries(nil))}, test.opts...)...)
+
+		req := newTestRequest("1.1.1.1")
+		req.RemoteAddr = "malformed"
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, req)
+
+		if res.Code != test.status || res.Body.String() != test.body {
+			t.Errorf("%s: expected %d '%s', got %d '%s'", test.name, test.status, test.body, res.Code, res.Body.String())
+		}
+	}
+
+	if _, err := New(0, "", "", WithBadRequestResponse(999, "")); err == nil {
+		t.Error("invalid status is accepted")
+	}
+}
@@ -3,18 +3,31 @@ package proxy
 import (
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
+	"strings"
 )
 
-type errorHandler func(http.ResponseWriter, *http.Request, error)
-
-func getRemoteAddr(r *http.Request) string {
-	forwarded := r.Header.Get("X-Forwarded-For")
+// getRemoteAddr returns a client address of a request. Headers are checked in the following order:
+// X-Forwarded-For, Forwarded, CF-Connecting-IP, X-Client-IP and X-Real-Ip.
+// The address of the peer is used when none of them is present.
+func getRemoteAddr(r *http.Request, selector *xffSelector) string {
+	forwarded := forwardedFor(r, selector)
 	if forwarded != "" {
 		return forwarded
 	}
 
+	if forwarded := r.Header.Get("Forwarded"); forwarded != "" {
+		if ip := parseForwarded(forwarded); ip != nil {
+			return ip.String()
+		}
+	}
+
+	for _, name := range []string{"Cf-Connecting-Ip", "X-Client-Ip"} {
+		if addr := strings.TrimSpace(r.Header.Get(name)); addr != "" {
+			return addr
+		}
+	}
+
 	realIp := r.Header.Get("X-Real-Ip")
 	if realIp != "" {
 		return realIp
@@ -23,29 +36,37 @@ func getRemoteAddr(r *http.Request) string {
 	return r.RemoteAddr
 }
 
+// getIP parses an address with an optional port.
+// IPv4-mapped IPv6 addresses like ::ffff:1.2.3.4 are converted to IPv4 addresses.
 func getIP(addr string) net.IP {
 	ip := net.ParseIP(addr)
 	if ip == nil {
 		if host, _, err := net.SplitHostPort(addr); err == nil {
-			return net.ParseIP(host)
+			ip = net.ParseIP(host)
 		}
 	}
 
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4
+	}
+
 	return ip
 }
 
-func serveReverseProxy(target string, res http.ResponseWriter, req *http.Request, errHandler errorHandler) {
-	targetUrl, _ := url.Parse(target)
-
-	proxy := httputil.NewSingleHostReverseProxy(targetUrl)
+func appendRequestPath(redirectUrl string, reqUrl *url.URL) string {
+	location, _ := url.Parse(redirectUrl)
 
-	proxy.ErrorHandler = errHandler
-
-	// Update the headers to allow for SSL redirection
-	req.URL.Host = targetUrl.Host
-	req.URL.Scheme = targetUrl.Scheme
-	req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
-	req.Host = targetUrl.Host
+	// leading slashes are collapsed, "//host" would be followed by browsers as a link to another host
+	path := "/" + strings.TrimLeft(reqUrl.Path, "/\\")
+	location.Path = strings.TrimSuffix(location.Path, "/") + path
+	location.RawPath = ""
+	if len(reqUrl.RawQuery) > 0 {
+		if len(location.RawQuery) > 0 {
+			location.RawQuery += "&" + reqUrl.RawQuery
+		} else {
+			location.RawQuery = reqUrl.RawQuery
+		}
+	}
 
-	proxy.ServeHTTP(res, req)
+	return location.String()
 }
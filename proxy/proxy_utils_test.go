@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectPreservePath(t *testing.T) {
+	tests := []struct {
+		redirect string
+		target   string
+		expected string
+	}{
+		{"https://blocked.example.com", "/news/today?lang=en", "https://blocked.example.com/news/today?lang=en"},
+		{"https://blocked.example.com/sorry/", "/news", "https://blocked.example.com/sorry/news"},
+		{"https://blocked.example.com/?from=geo", "/news?lang=en", "https://blocked.example.com/news?from=geo&lang=en"},
+		{"/blocked", "//evil.example.com/path", "/blocked/evil.example.com/path"},
+		{"", "//evil.example.com/path", "/evil.example.com/path"},
+		{"", "/\\evil.example.com", "/evil.example.com"},
+	}
+
+	for _, test := range tests {
+		p := openTestProxy(t,
+			WithResolver(countries(map[string]string{"1.1.1.1": "RU"})),
+			WithAllowedCountries([]string{"US"}),
+			WithRedirect(test.redirect),
+			WithRedirectPreservePath(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+test.target, nil)
+		req.RemoteAddr = "1.1.1.1:1234"
+
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, req)
+
+		if location := res.Header().Get("Location"); location != test.expected {
+			t.Errorf("%s + %s: expected Location %s, got %s", test.redirect, test.target, test.expected, location)
+		}
+	}
+}
+
+func TestGetIP(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+		length   int
+	}{
+		{"8.8.8.8", "8.8.8.8", net.IPv4len},
+		{"8.8.8.8:1234", "8.8.8.8", net.IPv4len},
+		{"::ffff:8.8.8.8", "8.8.8.8", net.IPv4len},
+		{"[::ffff:8.8.8.8]:1234", "8.8.8.8", net.IPv4len},
+		{"::ffff:808:808", "8.8.8.8", net.IPv4len},
+		{"2001:db8::1", "2001:db8::1", net.IPv6len},
+		{"[2001:db8::1]:1234", "2001:db8::1", net.IPv6len},
+		{"not an address", "<nil>", 0},
+	}
+
+	for _, test := range tests {
+		ip := getIP(test.addr)
+		if ip.String() != test.expected || len(ip) != test.length {
+			t.Errorf("%s: expected %s of %d bytes, got %s of %d bytes", test.addr, test.expected, test.length, ip, len(ip))
+		}
+	}
+}
+
+func TestIPv4MappedAddress(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"8.8.8.8": "US"})),
+		WithAllowedCountries([]string{"US"}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[::ffff:8.8.8.8]:1234"
+
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("IPv4-mapped address is not resolved as IPv4, got %d", res.Code)
+	}
+}
+
+func TestClientAddrHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{"peer", nil, "10.0.0.1:1234"},
+		{"CF-Connecting-IP", map[string]string{"CF-Connecting-IP": "1.1.1.1"}, "1.1.1.1"},
+		{"X-Client-IP", map[string]string{"X-Client-IP": "2.2.2.2"}, "2.2.2.2"},
+		{"CF-Connecting-IP over X-Client-IP",
+			map[string]string{"CF-Connecting-IP": "1.1.1.1", "X-Client-IP": "2.2.2.2"}, "1.1.1.1"},
+		{"X-Client-IP over X-Real-IP",
+			map[string]string{"X-Client-IP": "2.2.2.2", "X-Real-IP": "3.3.3.3"}, "2.2.2.2"},
+		{"X-Forwarded-For over CF-Connecting-IP",
+			map[string]string{"X-Forwarded-For": "4.4.4.4", "CF-Connecting-IP": "1.1.1.1"}, "4.4.4.4"},
+		{"Forwarded over X-Client-IP",
+			map[string]string{"Forwarded": "for=5.5.5.5", "X-Client-IP": "2.2.2.2"}, "5.5.5.5"},
+	}
+
+	trusted := openTestProxy(t, WithResolver(countries(nil)), WithTrustedProxies([]string{"10.0.0.0/8"}))
+	for _, test := range tests {
+		req := newTestRequest("10.0.0.1")
+		for name, value := range test.headers {
+			req.Header.Set(name, value)
+		}
+
+		if addr := trusted.clientAddr(req); addr != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, addr)
+		}
+	}
+
+	// headers of untrusted peers are ignored
+	req := newTestRequest("1.1.1.1")
+	req.Header.Set("CF-Connecting-IP", "2.2.2.2")
+	req.Header.Set("X-Client-IP", "3.3.3.3")
+	if addr := trusted.clientAddr(req); addr != req.RemoteAddr {
+		t.Errorf("expected the peer address %s of an untrusted peer, got %s", req.RemoteAddr, addr)
+	}
+}
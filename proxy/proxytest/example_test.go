@@ -0,0 +1,43 @@
+package proxytest_test
+
+import (
+	"fmt"
+
+	"geofilter/proxy"
+	"geofilter/proxy/proxytest"
+)
+
+func ExampleDecide() {
+	countries := proxytest.Countries{"1.2.3.4": "US", "5.6.7.8": "DE"}
+
+	for _, addr := range []string{"1.2.3.4", "5.6.7.8"} {
+		decision, res, err := proxytest.Decide(countries, proxytest.NewRequest(addr),
+			proxy.WithAllowedCountries([]string{"US"}),
+		)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		fmt.Println(addr, decision.Country, decision.Allowed, res.Code)
+	}
+
+	// Output:
+	// 1.2.3.4 US true 200
+	// 5.6.7.8 DE false 403
+}
+
+func ExampleForward() {
+	countries := proxytest.Countries{"1.2.3.4": "US"}
+
+	forwarded, res, err := proxytest.Forward(countries, "http://backend", proxytest.NewRequest("1.2.3.4"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(res.Code, forwarded.URL.Host)
+
+	// Output:
+	// 200 backend
+}
@@ -0,0 +1,141 @@
+// Package proxytest provides helpers to test applications which embed the geofilter middleware.
+//
+// A request can be run through a middleware with a fake resolver instead of a GeoIP database:
+//
+//	countries := proxytest.Countries{"1.2.3.4": "US", "5.6.7.8": "DE"}
+//	decision, res, err := proxytest.Decide(countries, proxytest.NewRequest("1.2.3.4"),
+//		proxy.WithAllowedCountries([]string{"US"}),
+//	)
+//
+// A request sent to the target by a proxy can be captured without a running target:
+//
+//	forwarded, res, err := proxytest.Forward(countries, "http://backend", proxytest.NewRequest("1.2.3.4"))
+package proxytest
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"geofilter/proxy"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/pkg/errors"
+)
+
+// Countries is a fake resolver which maps client addresses to ISO country codes.
+type Countries map[string]string
+
+// Resolve returns a country record of an address, addresses which are not mapped are not resolved.
+func (c Countries) Resolve(ip net.IP) (*geoip2.Country, error) {
+	code, ok := c[ip.String()]
+	if !ok {
+		return nil, errors.Errorf("address %s is not found", ip)
+	}
+
+	record := &geoip2.Country{}
+	record.Country.IsoCode = code
+	record.RegisteredCountry.IsoCode = code
+	return record, nil
+}
+
+// NewRequest returns a GET request coming from the client address.
+func NewRequest(addr string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = net.JoinHostPort(addr, "1234")
+	return req
+}
+
+// Decide runs a request through a middleware created with the options and a fake resolver of countries.
+// It returns the decision made by the middleware and the recorded response. Allowed requests are
+// answered with 200 OK by the next handler.
+func Decide(countries Countries, req *http.Request, opts ...proxy.StartOption) (proxy.Decision, *httptest.ResponseRecorder, error) {
+	opts = append(opts, proxy.WithResolver(countries.Resolve), proxy.WithQuiet())
+	p, err := proxy.New(0, "", "", opts...)
+	if err != nil {
+		return proxy.Decision{}, nil, err
+	}
+
+	if err := p.Open(); err != nil {
+		return proxy.Decision{}, nil, err
+	}
+	defer func() {
+		_ = p.Close()
+	}()
+
+	next := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	})
+
+	res := httptest.NewRecorder()
+	req = proxy.WithDecisionRecorder(req)
+	p.Middleware()(next).ServeHTTP(res, req)
+
+	decision, _ := proxy.DecisionFromContext(req.Context())
+	return decision, res, nil
+}
+
+// Transport is a stub transport which records requests sent to the target and responds with 200 OK.
+// It is passed to a proxy with proxy.WithTransport.
+type Transport struct {
+	lock     sync.Mutex
+	requests []*http.Request
+}
+
+// RoundTrip records a request and responds with an empty 200 OK response.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	t.requests = append(t.requests, req)
+	t.lock.Unlock()
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          ioutil.NopCloser(strings.NewReader("")),
+		ContentLength: 0,
+		Request:       req,
+	}, nil
+}
+
+// Requests returns requests sent to the target.
+func (t *Transport) Requests() []*http.Request {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return append([]*http.Request(nil), t.requests...)
+}
+
+// Forward runs a request through a proxy created with the options and a fake resolver of countries,
+// sending allowed requests to the target with a stub transport. It returns the request sent to the target,
+// which is nil when the request is blocked, and the recorded response.
+func Forward(countries Countries, target string, req *http.Request, opts ...proxy.StartOption) (*http.Request, *httptest.ResponseRecorder, error) {
+	transport := &Transport{}
+	opts = append(opts, proxy.WithResolver(countries.Resolve), proxy.WithTransport(transport), proxy.WithLazyTarget(), proxy.WithQuiet())
+	p, err := proxy.New(0, "", target, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.Open(); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = p.Close()
+	}()
+
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, req)
+
+	requests := transport.Requests()
+	if len(requests) == 0 {
+		return nil, res, nil
+	}
+
+	return requests[len(requests)-1], res, nil
+}
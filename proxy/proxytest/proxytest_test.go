@@ -0,0 +1,123 @@
+package proxytest
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"geofilter/proxy"
+)
+
+func TestCountriesResolve(t *testing.T) {
+	countries := Countries{"1.2.3.4": "US"}
+
+	record, err := countries.Resolve(net.ParseIP("1.2.3.4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Country.IsoCode != "US" {
+		t.Errorf("expected US, got %s", record.Country.IsoCode)
+	}
+
+	if _, err := countries.Resolve(net.ParseIP("5.6.7.8")); err == nil {
+		t.Error("address which is not mapped is resolved")
+	}
+}
+
+func TestDecide(t *testing.T) {
+	countries := Countries{"1.2.3.4": "US", "5.6.7.8": "DE"}
+
+	tests := []struct {
+		addr     string
+		expected proxy.Decision
+		status   int
+	}{
+		{"1.2.3.4", proxy.Decision{Allowed: true, Country: "US"}, http.StatusOK},
+		{"5.6.7.8", proxy.Decision{Allowed: false, Country: "DE"}, http.StatusForbidden},
+		{"9.9.9.9", proxy.Decision{Allowed: false}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		decision, res, err := Decide(countries, NewRequest(test.addr), proxy.WithAllowedCountries([]string{"US"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decision != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.addr, test.expected, decision)
+		}
+		if res.Code != test.status {
+			t.Errorf("%s: expected %d, got %d", test.addr, test.status, res.Code)
+		}
+	}
+}
+
+func TestDecideInvalidOption(t *testing.T) {
+	if _, _, err := Decide(Countries{}, NewRequest("1.2.3.4"), proxy.WithAllowedCountries(nil)); err == nil {
+		t.Error("invalid option is accepted")
+	}
+}
+
+func TestForward(t *testing.T) {
+	countries := Countries{"1.2.3.4": "US", "5.6.7.8": "DE"}
+	opts := []proxy.StartOption{proxy.WithAllowedCountries([]string{"US"})}
+
+	req := NewRequest("1.2.3.4")
+	req.URL.Path = "/news"
+	forwarded, res, err := Forward(countries, "http://backend:8080", req, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", res.Code)
+	}
+	if forwarded == nil {
+		t.Fatal("allowed request is not forwarded")
+	}
+	if forwarded.URL.Host != "backend:8080" || forwarded.URL.Path != "/news" {
+		t.Errorf("request is forwarded to %s", forwarded.URL)
+	}
+
+	forwarded, res, err = Forward(countries, "http://backend:8080", NewRequest("5.6.7.8"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forwarded != nil {
+		t.Error("blocked request is forwarded")
+	}
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", res.Code)
+	}
+}
+
+func TestForwardHeaders(t *testing.T) {
+	req := NewRequest("1.2.3.4")
+	req.Host = "example.com"
+	req.Header.Set("X-Geo-Country", "DE")
+
+	forwarded, _, err := Forward(Countries{"1.2.3.4": "US"}, "http://backend:8080", req,
+		proxy.WithHeaderName("X-Geo-Country", "X-Country-Code"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forwarded == nil {
+		t.Fatal("allowed request is not forwarded")
+	}
+
+	expected := map[string]string{
+		"X-Geo-Country":     "US",
+		"X-Country-Code":    "US",
+		"X-Forwarded-Host":  "example.com",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-For":   "1.2.3.4",
+	}
+	for name, value := range expected {
+		if got := forwarded.Header.Get(name); got != value {
+			t.Errorf("expected %s '%s', got '%s'", name, value, got)
+		}
+	}
+	if forwarded.Host != "backend:8080" {
+		t.Errorf("expected the target host, got %s", forwarded.Host)
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ptrLookupTimeout = time.Second
+	ptrCacheTTL      = 10 * time.Minute
+	ptrCacheSize     = 1 << 14
+)
+
+type ptrEntry struct {
+	// blockedName is a name matching a blocked pattern, it is empty when a client is not blocked
+	blockedName string
+	expires     time.Time
+}
+
+// ptrFilter blocks clients by patterns of their reverse DNS names.
+type ptrFilter struct {
+	patterns   []string
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+
+	lock  sync.Mutex
+	cache map[string]ptrEntry
+}
+
+// WithBlockedPTRPatterns is used to block requests from clients whose reverse DNS names match any of
+// the specified patterns, e.g. *.amazonaws.com. Patterns use the path.Match syntax and are case-insensitive.
+// Reverse lookups add latency, they are limited by a timeout and cached, failed lookups do not block requests.
+func WithBlockedPTRPatterns(patterns []string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(patterns) == 0 {
+			return nil, configError(ErrNoRule, "PTR patterns are not specified")
+		}
+
+		normalized := make([]string, 0, len(patterns))
+		for _, pattern := range patterns {
+			pattern = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(pattern), "."))
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, configError(ErrInvalidOption, "invalid PTR pattern '%s'", pattern)
+			}
+			normalized = append(normalized, pattern)
+		}
+
+		proxy.ptrFilter = &ptrFilter{
+			patterns:   normalized,
+			lookupAddr: net.DefaultResolver.LookupAddr,
+			cache:      make(map[string]ptrEntry),
+		}
+		return proxy, nil
+	}
+}
+
+func (f *ptrFilter) matches(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, pattern := range f.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blockedPTR returns a reverse DNS name of a client matching a blocked pattern or an empty string.
+func (p *geoProxy) blockedPTR(ctx context.Context, ip net.IP) string {
+	f := p.ptrFilter
+	key := ip.String()
+	now := p.clock.Now()
+
+	f.lock.Lock()
+	entry, ok := f.cache[key]
+	f.lock.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.blockedName
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ptrLookupTimeout)
+	defer cancel()
+
+	names, err := f.lookupAddr(ctx, key)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			p.requestLogger.Debug("reverse DNS lookup has failed",
+				p.ipField(ip),
+				zap.Error(err),
+			)
+			return ""
+		}
+	}
+
+	blockedName := ""
+	for _, name := range names {
+		if f.matches(name) {
+			blockedName = name
+			break
+		}
+	}
+
+	f.lock.Lock()
+	if len(f.cache) >= ptrCacheSize {
+		f.cache = make(map[string]ptrEntry)
+	}
+	f.cache[key] = ptrEntry{blockedName: blockedName, expires: now.Add(ptrCacheTTL)}
+	f.lock.Unlock()
+
+	return blockedName
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// stubPTRResolver returns reverse DNS names of addresses and counts lookups.
+type stubPTRResolver struct {
+	lock    sync.Mutex
+	names   map[string][]string
+	lookups map[string]int
+}
+
+func (r *stubPTRResolver) lookupAddr(_ context.Context, addr string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.lookups[addr]++
+	names, ok := r.names[addr]
+	if !ok {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: addr, IsTemporary: true}
+	}
+	if len(names) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+
+	return names, nil
+}
+
+func (r *stubPTRResolver) count(addr string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lookups[addr]
+}
+
+func TestBlockedPTRPatterns(t *testing.T) {
+	resolver := &stubPTRResolver{
+		names: map[string][]string{
+			"1.1.1.1": {"ec2-1-1-1-1.compute-1.amazonaws.com."},
+			"2.2.2.2": {"home.example.net."},
+			"3.3.3.3": {},
+		},
+		lookups: make(map[string]int),
+	}
+
+	clock := newFakeClock()
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "US", "3.3.3.3": "US", "4.4.4.4": "US"})),
+		WithAllowedCountries([]string{"US"}),
+		WithBlockedPTRPatterns([]string{"*.AmazonAWS.com."}),
+		WithClock(clock),
+	)
+	p.ptrFilter.lookupAddr = resolver.lookupAddr
+	handler := p.Middleware()(okHandler)
+
+	tests := []struct {
+		addr     string
+		expected int
+	}{
+		{"1.1.1.1", http.StatusForbidden},
+		{"2.2.2.2", http.StatusOK},
+		{"3.3.3.3", http.StatusOK},
+		{"4.4.4.4", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 2; i++ {
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, newTestRequest(test.addr))
+			if res.Code != test.expected {
+				t.Errorf("expected %d for %s, got %d", test.expected, test.addr, res.Code)
+			}
+		}
+	}
+
+	for addr, expected := range map[string]int{"1.1.1.1": 1, "2.2.2.2": 1, "3.3.3.3": 1, "4.4.4.4": 2} {
+		if lookups := resolver.count(addr); lookups != expected {
+			t.Errorf("expected %d lookups of %s, got %d", expected, addr, lookups)
+		}
+	}
+
+	clock.Advance(ptrCacheTTL)
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("1.1.1.1"))
+	if lookups := resolver.count("1.1.1.1"); lookups != 2 {
+		t.Errorf("expected the cached name to expire, got %d lookups", lookups)
+	}
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RateLimit is a maximum number of requests within a time window.
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// WithCountryRateLimits is used to limit numbers of requests coming from countries, e.g. US to 10 requests per second.
+// The limit is shared by all clients from a country, requests from other countries are not limited. Up to the limit
+// of requests are allowed at once, then requests are allowed at the average rate of the limit.
+// Requests exceeding the limit are answered with 429 Too Many Requests.
+func WithCountryRateLimits(limits map[string]RateLimit) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(limits) == 0 {
+			return nil, configError(ErrInvalidOption, "country rate limits are not specified")
+		}
+
+		limiters := make(map[string]*tokenLimiter)
+		for country, limit := range limits {
+			if limit.Requests <= 0 || limit.Window <= 0 {
+				return nil, configError(ErrInvalidOption, "invalid rate limit %d per %s of %s", limit.Requests, limit.Window, country)
+			}
+
+			codes, err := normalizeCountries([]string{country})
+			if err != nil {
+				return nil, err
+			}
+			limiters[codes[0]] = newTokenLimiter(limit.Requests, limit.Window)
+		}
+
+		proxy.countryLimiters = limiters
+		return proxy, nil
+	}
+}
+
+// rateLimited reports whether a request from a country exceeds its rate limit and responds with 429 if it does.
+func (p *geoProxy) rateLimited(res http.ResponseWriter, isoCode string) bool {
+	limiter, ok := p.countryLimiters[isoCode]
+	if !ok || limiter.allow(isoCode, p.clock.Now()) {
+		return false
+	}
+
+	p.requestLogger.Debug("country rate limit exceeded",
+		zap.String("country", isoCode),
+	)
+	p.countRequest(&p.stats.rateLimited, "rate_limited")
+	res.Header().Set("Retry-After", retryAfterSeconds(limiter.interval()))
+	res.WriteHeader(http.StatusTooManyRequests)
+	return true
+}
+
+// tokenLimiter limits requests with each key by token buckets. A bucket holds up to limit tokens and
+// is refilled at the rate of limit tokens per window, so bursts never exceed the limit.
+type tokenLimiter struct {
+	lock      sync.Mutex
+	limit     int
+	window    time.Duration
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newTokenLimiter(limit int, window time.Duration) *tokenLimiter {
+	return &tokenLimiter{
+		limit:   limit,
+		window:  window,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// interval returns the time it takes to refill a single token.
+func (l *tokenLimiter) interval() time.Duration {
+	return l.window / time.Duration(l.limit)
+}
+
+func (l *tokenLimiter) allow(key string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// buckets which have been refilled completely are dropped, so keys of past clients are not kept
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, bucket := range l.buckets {
+			if now.Sub(bucket.updated) >= l.window {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit), updated: now}
+		l.buckets[key] = bucket
+	}
+
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens += float64(l.limit) * float64(elapsed) / float64(l.window)
+		if bucket.tokens > float64(l.limit) {
+			bucket.tokens = float64(l.limit)
+		}
+		bucket.updated = now
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCountryRateLimits(t *testing.T) {
+	clock := newFakeClock()
+	p := openTestProxy(t,
+		WithClock(clock),
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU", "2.2.2.2": "RU", "3.3.3.3": "US"})),
+		WithCountryRateLimits(map[string]RateLimit{"ru": {Requests: 2, Window: time.Second}}),
+	)
+	handler := p.Middleware()(okHandler)
+
+	serve := func(addr string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest(addr))
+		return res
+	}
+	expect := func(step string, addr string, status int) {
+		t.Helper()
+		if res := serve(addr); res.Code != status {
+			t.Errorf("%s: expected %d for %s, got %d", step, status, addr, res.Code)
+		}
+	}
+
+	// the limit is shared by clients of a country
+	expect("burst", "1.1.1.1", http.StatusOK)
+	expect("burst", "2.2.2.2", http.StatusOK)
+	res := serve("1.1.1.1")
+	if res.Code != http.StatusTooManyRequests {
+		t.Errorf("burst: expected %d, got %d", http.StatusTooManyRequests, res.Code)
+	}
+	if retryAfter := res.Header().Get("Retry-After"); retryAfter != "1" {
+		t.Errorf("expected Retry-After 1, got '%s'", retryAfter)
+	}
+
+	// other countries are not limited
+	for i := 0; i < 10; i++ {
+		expect("unlimited", "3.3.3.3", http.StatusOK)
+	}
+
+	// a token is refilled in half of the window
+	clock.Advance(500 * time.Millisecond)
+	expect("refill", "1.1.1.1", http.StatusOK)
+	expect("refill", "1.1.1.1", http.StatusTooManyRequests)
+
+	// a new window doesn't let another burst through
+	clock.Advance(500 * time.Millisecond)
+	expect("boundary", "1.1.1.1", http.StatusOK)
+	expect("boundary", "1.1.1.1", http.StatusTooManyRequests)
+
+	// idle buckets are refilled up to the limit only
+	clock.Advance(time.Minute)
+	expect("idle", "1.1.1.1", http.StatusOK)
+	expect("idle", "1.1.1.1", http.StatusOK)
+	expect("idle", "1.1.1.1", http.StatusTooManyRequests)
+
+	if stats := p.Stats(); stats.RateLimited != 4 {
+		t.Errorf("expected 4 rate limited requests, got %d", stats.RateLimited)
+	}
+}
+
+func TestTokenLimiterDropsIdleBuckets(t *testing.T) {
+	limiter := newTokenLimiter(1, time.Second)
+	start := time.Now()
+
+	limiter.allow("1.1.1.1", start)
+	limiter.allow("2.2.2.2", start.Add(500*time.Millisecond))
+	if len(limiter.buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(limiter.buckets))
+	}
+
+	limiter.allow("2.2.2.2", start.Add(1500*time.Millisecond))
+	if _, ok := limiter.buckets["1.1.1.1"]; ok || len(limiter.buckets) != 1 {
+		t.Errorf("idle bucket is not dropped, got %d buckets", len(limiter.buckets))
+	}
+}
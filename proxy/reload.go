@@ -0,0 +1,60 @@
+package proxy
+
+import "sync"
+
+// reloadGroup serializes database reloads and coalesces concurrent requests to reload.
+// Requests arriving while a reload runs share a single reload started after it,
+// so each of them observes the database files at least as new as at the time of the request.
+type reloadGroup struct {
+	lock    sync.Mutex
+	running bool
+	pending *reloadCall
+	// run is held while a reload or an exclusive change of databases runs
+	run sync.Mutex
+}
+
+type reloadCall struct {
+	done chan struct{}
+	err  error
+}
+
+func (g *reloadGroup) do(reload func() error) error {
+	g.lock.Lock()
+	if g.pending == nil {
+		g.pending = &reloadCall{done: make(chan struct{})}
+	}
+	call := g.pending
+
+	if g.running {
+		g.lock.Unlock()
+		<-call.done
+		return call.err
+	}
+
+	g.running = true
+	for g.pending != nil {
+		current := g.pending
+		g.pending = nil
+		g.lock.Unlock()
+
+		g.run.Lock()
+		current.err = reload()
+		g.run.Unlock()
+		close(current.done)
+
+		g.lock.Lock()
+	}
+	g.running = false
+	g.lock.Unlock()
+
+	return call.err
+}
+
+// exclusive runs a change of databases which must not be coalesced with reloads, e.g. a swap to another path.
+// It waits for a running reload, reloads requested meanwhile wait for the change.
+func (g *reloadGroup) exclusive(change func() error) error {
+	g.run.Lock()
+	defer g.run.Unlock()
+
+	return change()
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"geofilter/internal/mmdbtest"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// overlapDetector reports changes of databases running at the same time.
+type overlapDetector struct {
+	running  int32
+	overlaps int32
+	calls    int32
+}
+
+func (d *overlapDetector) change() error {
+	if atomic.AddInt32(&d.running, 1) > 1 {
+		atomic.AddInt32(&d.overlaps, 1)
+	}
+	atomic.AddInt32(&d.calls, 1)
+	time.Sleep(time.Millisecond)
+	atomic.AddInt32(&d.running, -1)
+	return nil
+}
+
+func TestReloadGroupExclusive(t *testing.T) {
+	group := &reloadGroup{}
+	detector := &overlapDetector{}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = group.do(detector.change)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = group.exclusive(detector.change)
+		}()
+	}
+	wg.Wait()
+
+	if overlaps := atomic.LoadInt32(&detector.overlaps); overlaps > 0 {
+		t.Errorf("swaps and reloads have overlapped %d times", overlaps)
+	}
+	if calls := atomic.LoadInt32(&detector.calls); calls < 11 {
+		t.Errorf("expected every swap and at least one reload to run, got %d calls", calls)
+	}
+}
+
+func TestReloadGroupCoalescesConcurrentReloads(t *testing.T) {
+	group := &reloadGroup{}
+	detector := &overlapDetector{}
+	const callers = 50
+
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := group.do(detector.change); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if overlaps := atomic.LoadInt32(&detector.overlaps); overlaps > 0 {
+		t.Errorf("reloads have overlapped %d times", overlaps)
+	}
+	calls := atomic.LoadInt32(&detector.calls)
+	if calls == 0 || calls >= callers {
+		t.Errorf("expected concurrent reloads to be coalesced, got %d reloads for %d callers", calls, callers)
+	}
+}
+
+func TestConcurrentReloadsWhileServing(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithAllowedCountries([]string{"US"}),
+		WithPrefixCache(),
+	)
+
+	detector := &overlapDetector{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = p.reloads.do(func() error {
+				p.afterDbReload()
+				return detector.change()
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			res := httptest.NewRecorder()
+			p.Middleware()(okHandler).ServeHTTP(res, newTestRequest("1.1.1.1"))
+			if res.Code != http.StatusOK {
+				t.Errorf("expected 200 during reloads, got %d", res.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if overlaps := atomic.LoadInt32(&detector.overlaps); overlaps > 0 {
+		t.Errorf("reloads have overlapped %d times", overlaps)
+	}
+}
+
+func TestRewatchRemovedDirectory(t *testing.T) {
+	root, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dir := filepath.Join(root, "geoip")
+	path := filepath.Join(dir, "country.mmdb")
+	writeDatabase := func(country string) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := mmdbtest.Write(path, "GeoLite2-Country", map[string]interface{}{"1.1.1.0/24": mmdbtest.Country(country)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeDatabase("US")
+
+	clock := newFakeClock()
+	p, err := New(0, path, "", WithQuiet(), WithAutoReload(), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	waitForCountry := func(expected string) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			country, err := p.resolve(net.ParseIP("1.1.1.1"))
+			if err == nil && country.Country.IsoCode == expected {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("database is not reloaded, expected %s", expected)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	clock.waitForTimers(t, 1)
+
+	writeDatabase("DE")
+	clock.Advance(dirPollInterval)
+	waitForCountry("DE")
+
+	writeDatabase("FR")
+	waitForCountry("FR")
+}
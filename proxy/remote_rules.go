@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	rulesFetchTimeout = 10 * time.Second
+	maxRulesSize      = 1 << 20
+)
+
+// remoteRules are filtering rules fetched from a URL, in the format printed by the print-rules command.
+type remoteRules struct {
+	AllowCountries []string `json:"allow_countries"`
+	BlockCountries []string `json:"block_countries"`
+	AllowNetworks  []string `json:"allow_networks"`
+	BlockNetworks  []string `json:"block_networks"`
+	// BlockASNs are rejected, ASN rules can not be replaced at runtime
+	BlockASNs []uint `json:"block_asns"`
+}
+
+type remoteRulesSource struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	done     chan struct{}
+}
+
+// WithRemoteRules is used to periodically fetch filtering rules from an http(s) URL and apply them with SetFilter.
+// The URL returns a JSON object with allow_countries, block_countries, allow_networks and block_networks lists,
+// rules which are not listed are cleared. The current rules are kept when a fetch fails. Rules with
+// a block_asns list, which is printed by the print-rules command, are rejected since ASN rules can only be
+// configured with WithBlockedASNs.
+func WithRemoteRules(rulesUrl string, interval time.Duration) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		u, err := url.Parse(rulesUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, configError(ErrInvalidOption, "invalid rules URL '%s'", rulesUrl)
+		}
+
+		if interval <= 0 {
+			return nil, configError(ErrInvalidOption, "rules refresh interval must be positive")
+		}
+
+		proxy.remoteRules = &remoteRulesSource{
+			url:      rulesUrl,
+			interval: interval,
+			client:   &http.Client{Timeout: rulesFetchTimeout},
+		}
+		return proxy, nil
+	}
+}
+
+// option returns an option replacing country and network rules with the fetched ones.
+func (r remoteRules) option() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(r.BlockASNs) > 0 {
+			return nil, configError(ErrInvalidOption, "blocked ASNs are not supported by remote rules")
+		}
+		if len(r.AllowCountries) > 0 && len(r.BlockCountries) > 0 {
+			return nil, configError(ErrInvalidOption, "allowed and blocked countries are mutually exclusive")
+		}
+		if len(r.AllowNetworks) > 0 && len(r.BlockNetworks) > 0 {
+			return nil, configError(ErrInvalidOption, "allowed and blocked networks are mutually exclusive")
+		}
+
+		countries := WithNoFilter()
+		if len(r.AllowCountries) > 0 {
+			countries = WithAllowedCountries(r.AllowCountries)
+		} else if len(r.BlockCountries) > 0 {
+			countries = WithBlockedCountries(r.BlockCountries)
+		}
+
+		if _, err := countries(proxy); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(r.AllowNetworks) > 0:
+			return WithAllowedNetworks(r.AllowNetworks)(proxy)
+		case len(r.BlockNetworks) > 0:
+			return WithBlockedNetworks(r.BlockNetworks)(proxy)
+		}
+
+		proxy.networkFilter = func(net.IP) *blockReason {
+			return nil
+		}
+		proxy.allowedNetworks = nil
+		return proxy, nil
+	}
+}
+
+func (s *remoteRulesSource) fetch() (remoteRules, error) {
+	var rules remoteRules
+
+	res, err := s.client.Get(s.url)
+	if err != nil {
+		return rules, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return rules, errors.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	err = json.NewDecoder(http.MaxBytesReader(nil, res.Body, maxRulesSize)).Decode(&rules)
+	return rules, errors.Wrap(err, "invalid rules")
+}
+
+func (p *geoProxy) refreshRemoteRules() error {
+	rules, err := p.remoteRules.fetch()
+	if err != nil {
+		return err
+	}
+
+	return p.SetFilter(rules.option())
+}
+
+// startRemoteRules fetches remote rules and starts refreshing them.
+func (p *geoProxy) startRemoteRules() {
+	source := p.remoteRules
+	if source == nil {
+		return
+	}
+
+	if err := p.refreshRemoteRules(); err != nil {
+		p.logger.Warn("failed to fetch rules, keeping the configured rules",
+			zap.String("url", source.url),
+			zap.Error(err),
+		)
+	}
+
+	done := make(chan struct{})
+	source.done = done
+	go func() {
+		ticker := p.clock.NewTicker(source.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				if err := p.refreshRemoteRules(); err != nil {
+					p.logger.Warn("failed to refresh rules, keeping the current rules",
+						zap.String("url", source.url),
+						zap.Error(err),
+					)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func (p *geoProxy) stopRemoteRules() {
+	if p.remoteRules != nil && p.remoteRules.done != nil {
+		close(p.remoteRules.done)
+		p.remoteRules.done = nil
+	}
+}
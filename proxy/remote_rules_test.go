@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteRulesRefresh(t *testing.T) {
+	var fetches int32
+	rules := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			_, _ = res.Write([]byte(`{"block_countries": ["RU"]}`))
+			return
+		}
+		_, _ = res.Write([]byte(`{"allow_countries": ["RU"]}`))
+	}))
+	defer rules.Close()
+
+	clock := newFakeClock()
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU", "2.2.2.2": "US"})),
+		WithRemoteRules(rules.URL, time.Hour),
+		WithClock(clock),
+	)
+
+	status := func(addr string) int {
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, newTestRequest(addr))
+		return res.Code
+	}
+
+	if status("1.1.1.1") != http.StatusForbidden || status("2.2.2.2") != http.StatusOK {
+		t.Fatal("fetched rules are not applied")
+	}
+
+	clock.waitForTimers(t, 1)
+	clock.Advance(time.Hour)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status("2.2.2.2") != http.StatusForbidden {
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed rules are not applied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if status("1.1.1.1") != http.StatusOK {
+		t.Error("refreshed rules don't allow RU")
+	}
+}
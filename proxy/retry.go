@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// retryBackoff is a delay before the first retry, it is doubled for every next retry.
+const retryBackoff = 100 * time.Millisecond
+
+// retryTransport retries requests to a backend failed with a connection error.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+	clock   Clock
+	// maxBodySize is a maximum size of a request body buffered to retry non-idempotent requests.
+	// Zero disables retries of non-idempotent requests.
+	maxBodySize int64
+}
+
+// WithBackendRetries is used to configure a proxy to retry idempotent (GET and HEAD) requests
+// when a connection to the target fails. Cancelled and timed out requests are not retried.
+// Retries are delayed with an exponential backoff and are sent to the same target.
+func WithBackendRetries(retries int) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if retries <= 0 {
+			return nil, configError(ErrInvalidOption, "number of retries must be positive")
+		}
+
+		proxy.retries = retries
+		return proxy, nil
+	}
+}
+
+// WithNonIdempotentRetries is used together with WithBackendRetries to retry other requests
+// which failed to connect to the target.
+// Request bodies are buffered up to maxBodySize bytes, requests with larger bodies are not retried.
+func WithNonIdempotentRetries(maxBodySize int64) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if maxBodySize <= 0 {
+			return nil, configError(ErrInvalidOption, "maximum body size must be positive")
+		}
+
+		proxy.retryBodySize = maxBodySize
+		return proxy, nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// isRetryable reports whether a failed request can be sent again.
+// A request which failed to connect has never reached the target, so it is safe to retry it.
+func isRetryable(req *http.Request, err error) bool {
+	// a canceled request is not retried, the client is not waiting for a response anymore
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	var opErr *net.OpError
+	if stderrors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	// a timed out request may have been processed by the target
+	var netErr net.Error
+	if stderrors.Is(err, context.DeadlineExceeded) || (stderrors.As(err, &netErr) && netErr.Timeout()) {
+		return false
+	}
+
+	// other transport errors are connection errors, e.g. the target has closed a connection
+	return isIdempotent(req.Method)
+}
+
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody
+}
+
+// bufferBody reads a request body if it fits the limit.
+// It returns nil when the body is too large, the request body is replaced to be read from the beginning.
+func (t *retryTransport) bufferBody(req *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, t.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > t.maxBodySize {
+		req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+		return nil, nil
+	}
+
+	_ = req.Body.Close()
+	return body, nil
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req.Method) && t.maxBodySize == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if hasBody(req) {
+		if t.maxBodySize == 0 {
+			return t.next.RoundTrip(req)
+		}
+
+		buffered, err := t.bufferBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if buffered == nil {
+			return t.next.RoundTrip(req)
+		}
+		body = buffered
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req.WithContext(req.Context())
+		if body != nil {
+			attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		res, err := t.next.RoundTrip(attemptReq)
+		if err == nil || attempt >= t.retries || !isRetryable(req, err) {
+			return res, err
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, err
+		case <-t.clock.After(t.backoff << uint(attempt)):
+		}
+	}
+}
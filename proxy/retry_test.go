@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failOnceListener drops the first accepted connection.
+type failOnceListener struct {
+	net.Listener
+	failed int32
+}
+
+func (l *failOnceListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil && atomic.CompareAndSwapInt32(&l.failed, 0, 1) {
+		_ = conn.Close()
+		return l.Listener.Accept()
+	}
+
+	return conn, err
+}
+
+func TestBackendRetries(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &http.Server{Handler: okHandler}
+	go func() {
+		_ = backend.Serve(&failOnceListener{Listener: listener})
+	}()
+	defer backend.Close()
+
+	p, err := New(0, "", "http://"+listener.Addr().String(),
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithBackendRetries(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if res.Code != http.StatusOK {
+		t.Errorf("request to a backend failed once is not retried, got %d", res.Code)
+	}
+}
+
+// stubTransport fails requests with the errors in order, then succeeds.
+type stubTransport struct {
+	errs     []error
+	attempts int
+}
+
+func (t *stubTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.attempts++
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		return nil, err
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestRetryableErrors(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: io.EOF}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: io.EOF}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		method   string
+		ctx      context.Context
+		err      error
+		attempts int
+	}{
+		{"dial GET", http.MethodGet, context.Background(), dialErr, 2},
+		{"dial POST", http.MethodPost, context.Background(), dialErr, 2},
+		{"EOF GET", http.MethodGet, context.Background(), io.EOF, 2},
+		{"EOF POST", http.MethodPost, context.Background(), io.EOF, 1},
+		{"read GET", http.MethodGet, context.Background(), readErr, 2},
+		{"canceled", http.MethodGet, canceled, dialErr, 1},
+		{"timeout", http.MethodGet, context.Background(), context.DeadlineExceeded, 1},
+	}
+
+	for _, test := range tests {
+		next := &stubTransport{errs: []error{test.err}}
+		transport := &retryTransport{next: next, retries: 3, backoff: time.Millisecond, clock: realClock{}, maxBodySize: 1024}
+
+		req := httptest.NewRequest(test.method, "/", strings.NewReader("body")).WithContext(test.ctx)
+		_, _ = transport.RoundTrip(req)
+
+		if next.attempts != test.attempts {
+			t.Errorf("%s: expected %d attempts, got %d", test.name, test.attempts, next.attempts)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: io.EOF}
+	next := &stubTransport{errs: []error{dialErr, dialErr}}
+	transport := &retryTransport{next: next, retries: 2, backoff: 20 * time.Millisecond, clock: realClock{}}
+
+	started := time.Now()
+	if _, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20ms before the first retry and 40ms before the second one
+	if elapsed := time.Since(started); elapsed < 60*time.Millisecond {
+		t.Errorf("retries are not delayed, took %s", elapsed)
+	}
+}
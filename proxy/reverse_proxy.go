@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithRewriteRedirects is used to rewrite redirects issued by the target to the target's own host,
+// so they point to the host a client has requested.
+func WithRewriteRedirects() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.rewriteRedirects = true
+		return proxy, nil
+	}
+}
+
+// WithRequestModifier is used to modify allowed requests before they are sent to the target,
+// e.g. to add credentials or rewrite query parameters. Modifiers are called after geo headers are set,
+// in the order they were configured.
+func WithRequestModifier(modifier func(*http.Request)) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if modifier == nil {
+			return nil, configError(ErrInvalidOption, "request modifier is not specified")
+		}
+
+		proxy.requestModifiers = append(proxy.requestModifiers, modifier)
+		return proxy, nil
+	}
+}
+
+// WithViaHeader is used to add a Via header, e.g. "Via: 1.1 geofilter", to responses of the target,
+// so clients can tell that a response has passed through the proxy.
+func WithViaHeader() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.viaHeader = true
+		return proxy, nil
+	}
+}
+
+func requestScheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// forwardedProto returns a scheme requested by a client. X-Forwarded-Proto set by a trusted proxy is kept,
+// e.g. when TLS is terminated by a load balancer in front of the proxy.
+func (p *geoProxy) forwardedProto(req *http.Request) string {
+	if proto := req.Header.Get("X-Forwarded-Proto"); len(proto) > 0 && p.isTrustedPeer(req) {
+		return proto
+	}
+
+	return requestScheme(req)
+}
+
+func isUpgradeRequest(req *http.Request) bool {
+	if len(req.Header.Get("Upgrade")) == 0 {
+		return false
+	}
+
+	for _, value := range req.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// upgradeResponseWriter clears server timeouts of a hijacked connection,
+// otherwise they would terminate long-living upgraded connections such as WebSockets.
+type upgradeResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w upgradeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, rw, nil
+}
+
+func chainResponseModifiers(modifiers []func(*http.Response) error) func(*http.Response) error {
+	if len(modifiers) == 1 {
+		return modifiers[0]
+	}
+
+	return func(res *http.Response) error {
+		for _, modify := range modifiers {
+			if err := modify(res); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func addViaHeader(res *http.Response) error {
+	res.Header.Add("Via", fmt.Sprintf("%d.%d geofilter", res.ProtoMajor, res.ProtoMinor))
+	return nil
+}
+
+func redirectRewriter(targetUrl *url.URL) func(*http.Response) error {
+	return func(res *http.Response) error {
+		location := res.Header.Get("Location")
+		if len(location) == 0 {
+			return nil
+		}
+
+		locationUrl, err := url.Parse(location)
+		if err != nil || locationUrl.Host != targetUrl.Host {
+			return nil
+		}
+
+		locationUrl.Host = res.Request.Header.Get("X-Forwarded-Host")
+		locationUrl.Scheme = res.Request.Header.Get("X-Forwarded-Proto")
+		res.Header.Set("Location", locationUrl.String())
+
+		return nil
+	}
+}
+
+func (p *geoProxy) newReverseProxy(target string) *httputil.ReverseProxy {
+	targetUrl, _ := url.Parse(target)
+
+	proxy := httputil.NewSingleHostReverseProxy(targetUrl)
+
+	proxy.Transport = p.transport
+	proxy.ErrorHandler = p.errorHandler
+
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		clientHost := req.Host
+		clientScheme := p.forwardedProto(req)
+
+		director(req)
+
+		// Update the headers to allow for SSL redirection
+		req.Header.Set("X-Forwarded-Host", clientHost)
+		req.Header.Set("X-Forwarded-Proto", clientScheme)
+		req.Host = targetUrl.Host
+
+		for _, modify := range p.requestModifiers {
+			modify(req)
+		}
+	}
+
+	var modifiers []func(*http.Response) error
+	if p.rewriteRedirects {
+		modifiers = append(modifiers, redirectRewriter(targetUrl))
+	}
+	if p.overload != nil {
+		modifiers = append(modifiers, p.overload.modifyResponse(p.clock))
+	}
+	if p.viaHeader {
+		modifiers = append(modifiers, addViaHeader)
+	}
+	if len(modifiers) > 0 {
+		proxy.ModifyResponse = chainResponseModifiers(modifiers)
+	}
+
+	return proxy
+}
+
+func (p *geoProxy) serveReverseProxy(res http.ResponseWriter, req *http.Request) {
+	if p.overload != nil {
+		if delay, shedding := p.overload.shedding(p.clock.Now()); shedding {
+			writeOverloaded(res, delay)
+			return
+		}
+	}
+
+	// a shed request must not take the probe of a half-open breaker
+	if p.breaker != nil && !p.breaker.allow(p.clock.Now()) {
+		res.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if isUpgradeRequest(req) {
+		res = upgradeResponseWriter{res}
+	}
+
+	p.pickReverseProxy().ServeHTTP(res, req)
+}
@@ -0,0 +1,328 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForwardedProto(t *testing.T) {
+	var proto string
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		proto = req.Header.Get("X-Forwarded-Proto")
+	}))
+	defer backend.Close()
+
+	p, err := New(0, "", backend.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"10.0.0.1": "US", "1.1.1.1": "US"})),
+		WithTrustedProxies([]string{"10.0.0.0/8"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tests := []struct {
+		peer     string
+		header   string
+		expected string
+	}{
+		{"10.0.0.1", "https", "https"},
+		{"10.0.0.1", "", "http"},
+		{"1.1.1.1", "https", "http"},
+	}
+
+	for _, test := range tests {
+		req := newTestRequest(test.peer)
+		if len(test.header) > 0 {
+			req.Header.Set("X-Forwarded-Proto", test.header)
+		}
+
+		proto = ""
+		p.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+		if proto != test.expected {
+			t.Errorf("%s with '%s': expected X-Forwarded-Proto '%s', got '%s'", test.peer, test.header, test.expected, proto)
+		}
+	}
+}
+
+// echoUpgradeHandler switches protocols and echoes lines sent over the hijacked connection.
+var echoUpgradeHandler = http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Upgrade") != "websocket" {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	conn, rw, err := res.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	_ = rw.Flush()
+
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		_, _ = rw.WriteString(line)
+		_ = rw.Flush()
+	}
+})
+
+func TestUpgradeOutlivesServerTimeouts(t *testing.T) {
+	backend := httptest.NewServer(echoUpgradeHandler)
+	defer backend.Close()
+
+	p, err := New(0, "", backend.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"127.0.0.1": "US"})),
+		WithServerTimeouts(time.Second, 100*time.Millisecond, 100*time.Millisecond, time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := p.newServer(p.Handler())
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = conn.Write([]byte("GET /socket HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", res.StatusCode)
+	}
+
+	// the upgraded connection outlives the read and write timeouts
+	time.Sleep(300 * time.Millisecond)
+
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("upgraded connection is closed: %v", err)
+	}
+	if line != "ping\n" {
+		t.Errorf("unexpected echo '%s'", line)
+	}
+}
+
+// deadlineConn records deadlines set on a connection.
+type deadlineConn struct {
+	net.Conn
+	deadline *time.Time
+}
+
+func (c deadlineConn) SetDeadline(t time.Time) error {
+	*c.deadline = t
+	return nil
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (r hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return r.conn, nil, nil
+}
+
+func TestUpgradeResponseWriterClearsDeadline(t *testing.T) {
+	deadline := time.Now()
+	res := upgradeResponseWriter{hijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: deadlineConn{deadline: &deadline}}}
+
+	if _, _, err := res.Hijack(); err != nil {
+		t.Fatal(err)
+	}
+	if !deadline.IsZero() {
+		t.Errorf("deadline of a hijacked connection is not cleared: %s", deadline)
+	}
+
+	if _, _, err := (upgradeResponseWriter{httptest.NewRecorder()}).Hijack(); err == nil {
+		t.Error("hijacking is reported for a writer which doesn't support it")
+	}
+}
+
+func TestClientCancellation(t *testing.T) {
+	received := make(chan struct{})
+	canceled := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		close(received)
+		select {
+		case <-req.Context().Done():
+			close(canceled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer backend.Close()
+
+	p, err := New(0, "", backend.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"127.0.0.1": "US"})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	server := httptest.NewServer(p.Handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		failed <- err
+	}()
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request is not proxied to the backend")
+	}
+	cancel()
+
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Error("backend request is not canceled after the client has disconnected")
+	}
+	if err := <-failed; err == nil {
+		t.Error("canceled client request has succeeded")
+	}
+}
+
+func TestRequestModifier(t *testing.T) {
+	var token, query string
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		token = req.Header.Get("Authorization")
+		query = req.URL.RawQuery
+	}))
+	defer backend.Close()
+
+	var country string
+	p, err := New(0, "", backend.URL,
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithRequestModifier(func(req *http.Request) {
+			country = req.Header.Get(geoHeaderName)
+			req.Header.Set("Authorization", "Bearer secret")
+		}),
+		WithRequestModifier(func(req *http.Request) {
+			q := req.URL.Query()
+			q.Set("country", country)
+			q.Del("debug")
+			req.URL.RawQuery = q.Encode()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/?debug=1", nil)
+	req.RemoteAddr = "1.1.1.1:1234"
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, res.Code)
+	}
+	if token != "Bearer secret" {
+		t.Errorf("expected the added Authorization header, got '%s'", token)
+	}
+	if query != "country=US" {
+		t.Errorf("expected the rewritten query 'country=US', got '%s'", query)
+	}
+
+	if _, err := New(0, "", "", WithRequestModifier(nil)); err == nil {
+		t.Error("nil request modifier is accepted")
+	}
+}
+
+func TestViaHeader(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.Header().Set("Via", "1.1 upstream")
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name     string
+		opts     []StartOption
+		expected []string
+	}{
+		{"enabled", []StartOption{WithViaHeader()}, []string{"1.1 upstream", "1.1 geofilter"}},
+		{"disabled", nil, []string{"1.1 upstream"}},
+	}
+
+	for _, test := range tests {
+		opts := append([]StartOption{WithQuiet(), WithResolver(countries(map[string]string{"1.1.1.1": "US"}))}, test.opts...)
+		p, err := New(0, "", backend.URL, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		res := httptest.NewRecorder()
+		p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+		_ = p.Close()
+
+		if via := res.Header().Values("Via"); strings.Join(via, ", ") != strings.Join(test.expected, ", ") {
+			t.Errorf("%s: expected Via %v, got %v", test.name, test.expected, via)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+)
+
+type methodRule struct {
+	filter          filterFunc
+	networkFilter   networkFilterFunc
+	allowedNetworks []*net.IPNet
+}
+
+// ruleSet is a set of filtering rules applied to a request.
+type ruleSet struct {
+	filter        filterFunc
+	networkFilter networkFilterFunc
+	// allowedNetworks are set when the network filter is an allow list
+	allowedNetworks []*net.IPNet
+}
+
+// WithMethodRule is used to apply different filtering rules to requests with the specified HTTP methods.
+// The option accepts the same options as SetFilter, e.g. WithAllowedCountries. Requests with the specified
+// methods are filtered by the rule instead of the global rules, requests with other methods are not affected.
+func WithMethodRule(methods []string, opt StartOption) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(methods) == 0 {
+			return nil, configError(ErrNoRule, "methods are not specified")
+		}
+
+		staging := &geoProxy{}
+		if _, err := opt(staging); err != nil {
+			return nil, err
+		}
+
+		if staging.filter == nil && staging.networkFilter == nil {
+			return nil, configError(ErrNoRule, "method rule does not define filtering rules")
+		}
+
+		if proxy.methodRules == nil {
+			proxy.methodRules = make(map[string]methodRule)
+		}
+
+		for _, method := range methods {
+			proxy.methodRules[strings.ToUpper(strings.TrimSpace(method))] = methodRule{
+				filter:          staging.filter,
+				networkFilter:   staging.networkFilter,
+				allowedNetworks: staging.allowedNetworks,
+			}
+		}
+
+		return proxy, nil
+	}
+}
+
+// SetFilter atomically replaces filtering rules of a running proxy.
+// It accepts the options defining country or network rules, e.g. WithAllowedCountries or WithBlockedNetworks.
+func (p *geoProxy) SetFilter(opt StartOption) error {
+	staging := &geoProxy{}
+	if _, err := opt(staging); err != nil {
+		return err
+	}
+
+	if staging.filter == nil && staging.networkFilter == nil {
+		return configError(ErrNoRule, "option does not define filtering rules")
+	}
+
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+
+	if staging.filter != nil {
+		p.filter = staging.filter
+	}
+	if staging.networkFilter != nil {
+		p.networkFilter = staging.networkFilter
+		p.allowedNetworks = staging.allowedNetworks
+	}
+
+	return nil
+}
+
+// filters returns filtering rules for a request method.
+func (p *geoProxy) filters(method string) ruleSet {
+	p.filterLock.RLock()
+	rules := ruleSet{
+		filter:          p.filter,
+		networkFilter:   p.networkFilter,
+		allowedNetworks: p.allowedNetworks,
+	}
+	p.filterLock.RUnlock()
+
+	if rule, ok := p.methodRules[method]; ok {
+		if rule.filter != nil {
+			rules.filter = rule.filter
+		}
+		if rule.networkFilter != nil {
+			rules.networkFilter = rule.networkFilter
+			rules.allowedNetworks = rule.allowedNetworks
+		}
+	}
+
+	return rules
+}
@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+func TestSetFilterWhileServing(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+	)
+	handler := p.Middleware()(okHandler)
+
+	stop := make(chan struct{})
+	swapped := make(chan error, 1)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				swapped <- nil
+				return
+			default:
+			}
+
+			countries := []string{"US"}
+			if i%2 == 1 {
+				countries = []string{"RU"}
+			}
+			if err := p.SetFilter(WithAllowedCountries(countries)); err != nil {
+				swapped <- err
+				return
+			}
+			if err := p.SetFilter(WithBlockedNetworks([]string{"3.3.3.0/24"})); err != nil {
+				swapped <- err
+				return
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, newTestRequest("1.1.1.1"))
+				if res.Code != http.StatusOK && res.Code != http.StatusForbidden {
+					t.Errorf("unexpected status %d", res.Code)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+
+	if err := <-swapped; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SetFilter(WithAllowedCountries([]string{"RU"})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr     string
+		expected int
+	}{
+		{"1.1.1.1", http.StatusForbidden},
+		{"2.2.2.2", http.StatusOK},
+		{"3.3.3.3", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest(test.addr))
+
+		if res.Code != test.expected {
+			t.Errorf("%s: expected %d after the swap, got %d", test.addr, test.expected, res.Code)
+		}
+	}
+}
+
+func TestSetFilterWithoutRules(t *testing.T) {
+	p := openTestProxy(t, WithResolver(countries(nil)))
+
+	if err := p.SetFilter(WithQuiet()); err == nil {
+		t.Error("option without filtering rules is accepted")
+	}
+}
+
+// euCountries is a resolver which marks DE and FR as the European Union member states.
+func euCountries(ip net.IP) (*geoip2.Country, error) {
+	record, err := countries(map[string]string{"1.1.1.1": "DE", "2.2.2.2": "FR", "3.3.3.3": "US"})(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Country.IsInEuropeanUnion = record.Country.IsoCode == "DE" || record.Country.IsoCode == "FR"
+	return record, nil
+}
+
+func TestEURules(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []StartOption
+		expected map[string]int
+	}{
+		{
+			name:     "allow EU",
+			opts:     []StartOption{WithAllowEUOnly()},
+			expected: map[string]int{"1.1.1.1": http.StatusOK, "2.2.2.2": http.StatusOK, "3.3.3.3": http.StatusForbidden},
+		},
+		{
+			name:     "allow EU and US",
+			opts:     []StartOption{WithAllowEUOnly(), WithAllowedCountries([]string{"US"})},
+			expected: map[string]int{"1.1.1.1": http.StatusOK, "2.2.2.2": http.StatusOK, "3.3.3.3": http.StatusOK},
+		},
+		{
+			name:     "block EU",
+			opts:     []StartOption{WithBlockEU()},
+			expected: map[string]int{"1.1.1.1": http.StatusForbidden, "2.2.2.2": http.StatusForbidden, "3.3.3.3": http.StatusOK},
+		},
+	}
+
+	for _, test := range tests {
+		p := openTestProxy(t, append([]StartOption{WithResolver(euCountries)}, test.opts...)...)
+		handler := p.Middleware()(okHandler)
+
+		for addr, expected := range test.expected {
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, newTestRequest(addr))
+
+			if res.Code != expected {
+				t.Errorf("%s: expected %d for %s, got %d", test.name, expected, addr, res.Code)
+			}
+		}
+	}
+}
+
+func TestMethodRule(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "RU", "2.2.2.2": "US"})),
+		WithMethodRule([]string{"post", " PUT "}, WithAllowedCountries([]string{"US"})),
+	)
+	handler := p.Middleware()(okHandler)
+
+	tests := []struct {
+		method   string
+		addr     string
+		expected int
+	}{
+		{http.MethodGet, "1.1.1.1", http.StatusOK},
+		{http.MethodPost, "1.1.1.1", http.StatusForbidden},
+		{http.MethodPut, "1.1.1.1", http.StatusForbidden},
+		{http.MethodPost, "2.2.2.2", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := newTestRequest(test.addr)
+		req.Method = test.method
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != test.expected {
+			t.Errorf("%s from %s: expected %d, got %d", test.method, test.addr, test.expected, res.Code)
+		}
+	}
+}
+
+func TestAllowListUnion(t *testing.T) {
+	resolver := countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "DE", "3.3.3.3": "RU"})
+
+	tests := []struct {
+		name     string
+		opts     []StartOption
+		expected map[string]int
+	}{
+		{
+			name:     "two allow lists",
+			opts:     []StartOption{WithAllowedCountries([]string{"US"}), WithAllowedCountries([]string{"de"})},
+			expected: map[string]int{"1.1.1.1": http.StatusOK, "2.2.2.2": http.StatusOK, "3.3.3.3": http.StatusForbidden},
+		},
+		{
+			name:     "block list replaces allow lists",
+			opts:     []StartOption{WithAllowedCountries([]string{"US"}), WithAllowedCountries([]string{"DE"}), WithBlockedCountries([]string{"US"})},
+			expected: map[string]int{"1.1.1.1": http.StatusForbidden, "2.2.2.2": http.StatusOK, "3.3.3.3": http.StatusOK},
+		},
+		{
+			name:     "allow list after block list",
+			opts:     []StartOption{WithBlockedCountries([]string{"US"}), WithAllowedCountries([]string{"DE"})},
+			expected: map[string]int{"1.1.1.1": http.StatusForbidden, "2.2.2.2": http.StatusOK, "3.3.3.3": http.StatusForbidden},
+		},
+	}
+
+	for _, test := range tests {
+		p := openTestProxy(t, append([]StartOption{WithResolver(resolver)}, test.opts...)...)
+		handler := p.Middleware()(okHandler)
+
+		for addr, expected := range test.expected {
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, newTestRequest(addr))
+
+			if res.Code != expected {
+				t.Errorf("%s: expected %d for %s, got %d", test.name, expected, addr, res.Code)
+			}
+		}
+	}
+}
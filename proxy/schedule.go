@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"strings"
+	"time"
+)
+
+// TimeWindow is a time of day interval defined by offsets from midnight.
+// A window with From greater than To spans midnight, e.g. 22:00-06:00.
+type TimeWindow struct {
+	From time.Duration
+	To   time.Duration
+}
+
+type countrySchedule struct {
+	windows  []TimeWindow
+	location *time.Location
+}
+
+// ParseTimeWindow parses a time window in the 15:04-15:04 format.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, configError(ErrInvalidOption, "invalid time window '%s', expected HH:MM-HH:MM", s)
+	}
+
+	var offsets [2]time.Duration
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return TimeWindow{}, configError(ErrInvalidOption, "invalid time window '%s', expected HH:MM-HH:MM", s)
+		}
+		offsets[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	return TimeWindow{From: offsets[0], To: offsets[1]}, nil
+}
+
+func (w TimeWindow) contains(offset time.Duration) bool {
+	if w.From <= w.To {
+		return offset >= w.From && offset < w.To
+	}
+
+	return offset >= w.From || offset < w.To
+}
+
+// WithCountrySchedule is used to allow requests coming from a country only during the specified time windows
+// in the tz timezone, e.g. "Europe/Berlin". Requests outside the windows are blocked, requests inside
+// the windows are filtered by the other rules. The timezone database of the system is used, "UTC" is always available.
+func WithCountrySchedule(country string, windows []TimeWindow, tz string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		codes, err := normalizeCountries([]string{country})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(windows) == 0 {
+			return nil, configError(ErrNoRule, "time windows are not specified")
+		}
+
+		for _, w := range windows {
+			if w.From < 0 || w.From >= 24*time.Hour || w.To < 0 || w.To > 24*time.Hour {
+				return nil, configError(ErrInvalidOption, "time window offsets must be within a day")
+			}
+		}
+
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, configError(ErrInvalidOption, "unknown timezone '%s'", tz)
+		}
+
+		if proxy.schedules == nil {
+			proxy.schedules = make(map[string]countrySchedule)
+		}
+		proxy.schedules[codes[0]] = countrySchedule{
+			windows:  windows,
+			location: location,
+		}
+
+		return proxy, nil
+	}
+}
+
+// scheduleAllows reports whether requests from a country are allowed at the moment.
+func (p *geoProxy) scheduleAllows(isoCode string) bool {
+	schedule, ok := p.schedules[isoCode]
+	if !ok {
+		return true
+	}
+
+	now := p.clock.Now().In(schedule.location)
+	offset := time.Duration(now.Hour())*time.Hour +
+		time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second
+
+	for _, w := range schedule.windows {
+		if w.contains(offset) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCountrySchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("timezone database is not available")
+	}
+
+	office, err := ParseTimeWindow("09:00-18:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	night, err := ParseTimeWindow("22:00-02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := newFakeClock()
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "DE", "2.2.2.2": "US"})),
+		WithAllowedCountries([]string{"DE", "US"}),
+		WithCountrySchedule("DE", []TimeWindow{office, night}, "Europe/Berlin"),
+		WithClock(clock),
+	)
+
+	tests := []struct {
+		addr     string
+		time     string
+		expected int
+	}{
+		{"1.1.1.1", "08:59:59", http.StatusForbidden},
+		{"1.1.1.1", "09:00:00", http.StatusOK},
+		{"1.1.1.1", "17:59:59", http.StatusOK},
+		{"1.1.1.1", "18:00:00", http.StatusForbidden},
+		{"1.1.1.1", "21:59:59", http.StatusForbidden},
+		{"1.1.1.1", "22:00:00", http.StatusOK},
+		{"1.1.1.1", "00:00:00", http.StatusOK},
+		{"1.1.1.1", "01:59:59", http.StatusOK},
+		{"1.1.1.1", "02:00:00", http.StatusForbidden},
+		{"2.2.2.2", "03:00:00", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		at, err := time.ParseInLocation("2006-01-02 15:04:05", "2020-06-15 "+test.time, berlin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the proxy converts the time to the schedule timezone itself
+		clock.Set(at.UTC())
+
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, newTestRequest(test.addr))
+
+		if res.Code != test.expected {
+			t.Errorf("%s at %s: expected %d, got %d", test.addr, test.time, test.expected, res.Code)
+		}
+	}
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	for _, s := range []string{"", "09:00", "9-18", "09:00-25:00", "09:00-18:00-20:00"} {
+		if _, err := ParseTimeWindow(s); err == nil {
+			t.Errorf("invalid window '%s' is parsed", s)
+		}
+	}
+}
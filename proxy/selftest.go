@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// WithStartupSelfTest is used to verify a GeoIP database before a proxy starts serving requests.
+// The expected map contains ISO country codes of IP addresses, the proxy fails to start when
+// any of the addresses is resolved to a different country.
+func WithStartupSelfTest(expected map[string]string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(expected) == 0 {
+			return nil, configError(ErrInvalidOption, "self-test addresses are not specified")
+		}
+
+		for addr := range expected {
+			if net.ParseIP(addr) == nil {
+				return nil, configError(ErrInvalidOption, "invalid self-test address '%s'", addr)
+			}
+		}
+
+		proxy.selfTest = expected
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) runSelfTest() error {
+	addrs := make([]string, 0, len(p.selfTest))
+	for addr := range p.selfTest {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var failures []string
+	for _, addr := range addrs {
+		expected := strings.ToUpper(p.selfTest[addr])
+
+		var actual string
+		if country, err := p.resolve(net.ParseIP(addr)); err == nil {
+			actual = p.selectCountry(country).isoCode
+		}
+
+		if actual != expected {
+			failures = append(failures, addr+": expected '"+expected+"', got '"+actual+"'")
+		}
+	}
+
+	if len(failures) > 0 {
+		return configError(ErrDatabase, "database self-test failed: %s", strings.Join(failures, "; "))
+	}
+
+	p.logger.Info("database self-test passed",
+		zap.Int("addresses", len(addrs)),
+	)
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStartupSelfTest(t *testing.T) {
+	resolver := WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "DE"}))
+
+	openTestProxy(t, resolver, WithStartupSelfTest(map[string]string{"1.1.1.1": "us", "2.2.2.2": "DE"}))
+
+	p, err := New(0, "", "", WithQuiet(), resolver,
+		WithStartupSelfTest(map[string]string{"1.1.1.1": "US", "2.2.2.2": "FR", "3.3.3.3": "GB"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	err = p.Open()
+	if err == nil {
+		t.Fatal("proxy is opened with a failing self-test")
+	}
+	for _, expected := range []string{"2.2.2.2: expected 'FR', got 'DE'", "3.3.3.3: expected 'GB', got ''"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("'%s' is not reported in '%v'", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "1.1.1.1") {
+		t.Errorf("passed address is reported in '%v'", err)
+	}
+}
+
+func TestStartupSelfTestValidation(t *testing.T) {
+	for _, expected := range []map[string]string{nil, {"not an address": "US"}} {
+		if _, err := New(0, "", "", WithStartupSelfTest(expected)); err == nil {
+			t.Errorf("self-test %v is accepted", expected)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WithServerTiming is used to add a Server-Timing header with the duration of a country lookup
+// to responses, e.g. "Server-Timing: geolookup;dur=0.042".
+func WithServerTiming() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.serverTiming = true
+		return proxy, nil
+	}
+}
+
+func setServerTiming(res http.ResponseWriter, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	res.Header().Add("Server-Timing", fmt.Sprintf("geolookup;dur=%.3f", ms))
+}
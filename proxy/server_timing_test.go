@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+func TestServerTiming(t *testing.T) {
+	clock := newFakeClock()
+	resolve := countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})
+
+	p := openTestProxy(t,
+		WithResolver(func(ip net.IP) (*geoip2.Country, error) {
+			clock.Advance(42 * time.Microsecond)
+			return resolve(ip)
+		}),
+		WithClock(clock),
+		WithAllowedCountries([]string{"US"}),
+		WithServerTiming(),
+	)
+	handler := p.Middleware()(okHandler)
+
+	for addr, status := range map[string]int{"1.1.1.1": http.StatusOK, "2.2.2.2": http.StatusForbidden} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest(addr))
+
+		if res.Code != status {
+			t.Errorf("%s: expected %d, got %d", addr, status, res.Code)
+		}
+		if timing := res.Header().Get("Server-Timing"); timing != "geolookup;dur=0.042" {
+			t.Errorf("%s: unexpected Server-Timing '%s'", addr, timing)
+		}
+	}
+}
+
+func TestServerTimingFormat(t *testing.T) {
+	format := regexp.MustCompile(`^geolookup;dur=\d+\.\d{3}$`)
+
+	for _, d := range []time.Duration{0, time.Microsecond, 1500 * time.Microsecond, 2 * time.Second} {
+		res := httptest.NewRecorder()
+		setServerTiming(res, d)
+
+		if timing := res.Header().Get("Server-Timing"); !format.MatchString(timing) {
+			t.Errorf("%s: invalid Server-Timing '%s'", d, timing)
+		}
+	}
+}
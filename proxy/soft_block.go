@@ -0,0 +1,15 @@
+package proxy
+
+// WithSoftBlock is used to pass requests which would be blocked to the target, tagged with
+// the specified header set to "true", so the target decides how to handle them.
+// Requests with unparseable client addresses are still rejected.
+func WithSoftBlock(headerName string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(headerName) == 0 {
+			return nil, configError(ErrInvalidOption, "soft block header name is not specified")
+		}
+
+		proxy.softBlockHeader = headerName
+		return proxy, nil
+	}
+}
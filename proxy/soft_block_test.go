@@ -0,0 +1,26 @@
+package proxy
+
+import "testing"
+
+func TestSoftBlock(t *testing.T) {
+	header := forwardedHeaders(t, newTestRequest("1.1.1.1"),
+		WithBlockedCountries([]string{"US"}),
+		WithSoftBlock("X-Geo-Blocked"),
+	)
+	if tag := header.Get("X-Geo-Blocked"); tag != "true" {
+		t.Errorf("soft-blocked request is not tagged, got '%s'", tag)
+	}
+	if country := header.Get(geoHeaderName); country != "US" {
+		t.Errorf("expected country header US in a soft-blocked request, got '%s'", country)
+	}
+
+	req := newTestRequest("1.1.1.1")
+	req.Header.Set("X-Geo-Blocked", "true")
+	header = forwardedHeaders(t, req,
+		WithAllowedCountries([]string{"US"}),
+		WithSoftBlock("X-Geo-Blocked"),
+	)
+	if tag := header.Get("X-Geo-Blocked"); len(tag) > 0 {
+		t.Errorf("tag sent by a client is passed with an allowed request: %s", tag)
+	}
+}
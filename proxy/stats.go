@@ -0,0 +1,48 @@
+package proxy
+
+import "sync/atomic"
+
+// Stats contains numbers of requests handled by a proxy.
+type Stats struct {
+	// Allowed is a number of requests passed to the target.
+	Allowed uint64 `json:"allowed"`
+	// Blocked is a number of blocked or soft-blocked requests.
+	Blocked uint64 `json:"blocked"`
+	// Unresolved is a number of blocked requests whose country has not been resolved.
+	Unresolved uint64 `json:"unresolved"`
+	// Invalid is a number of requests rejected because of unparseable client addresses.
+	Invalid uint64 `json:"invalid"`
+	// RateLimited is a number of requests rejected because of country rate limits.
+	RateLimited uint64 `json:"rate_limited"`
+}
+
+type counters struct {
+	allowed     uint64
+	blocked     uint64
+	unresolved  uint64
+	invalid     uint64
+	rateLimited uint64
+}
+
+// Stats returns numbers of requests handled since the proxy has been created or since the last reset.
+func (p *geoProxy) Stats() Stats {
+	return Stats{
+		Allowed:     atomic.LoadUint64(&p.stats.allowed),
+		Blocked:     atomic.LoadUint64(&p.stats.blocked),
+		Unresolved:  atomic.LoadUint64(&p.stats.unresolved),
+		Invalid:     atomic.LoadUint64(&p.stats.invalid),
+		RateLimited: atomic.LoadUint64(&p.stats.rateLimited),
+	}
+}
+
+// SnapshotAndReset returns numbers of handled requests and resets them to zero, e.g. for periodic reporting.
+// Each counter is swapped atomically, so no request is lost between snapshots.
+func (p *geoProxy) SnapshotAndReset() Stats {
+	return Stats{
+		Allowed:     atomic.SwapUint64(&p.stats.allowed, 0),
+		Blocked:     atomic.SwapUint64(&p.stats.blocked, 0),
+		Unresolved:  atomic.SwapUint64(&p.stats.unresolved, 0),
+		Invalid:     atomic.SwapUint64(&p.stats.invalid, 0),
+		RateLimited: atomic.SwapUint64(&p.stats.rateLimited, 0),
+	}
+}
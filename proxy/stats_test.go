@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotAndReset(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+	)
+	handler := p.Middleware()(okHandler)
+
+	const (
+		workers  = 4
+		requests = 500
+	)
+
+	stop := make(chan struct{})
+	snapshots := make(chan Stats)
+	go func() {
+		var sum Stats
+		for {
+			select {
+			case <-stop:
+				snapshots <- sum
+				return
+			default:
+			}
+
+			s := p.SnapshotAndReset()
+			sum.Allowed += s.Allowed
+			sum.Blocked += s.Blocked
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("1.1.1.1"))
+				handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("2.2.2.2"))
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+
+	total := <-snapshots
+	last := p.SnapshotAndReset()
+	total.Allowed += last.Allowed
+	total.Blocked += last.Blocked
+
+	if total.Allowed != workers*requests || total.Blocked != workers*requests {
+		t.Errorf("expected %d allowed and blocked requests over all snapshots, got %+v", workers*requests, total)
+	}
+	if stats := p.Stats(); stats != (Stats{}) {
+		t.Errorf("expected counters to be reset, got %+v", stats)
+	}
+}
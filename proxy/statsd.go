@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const statsdPrefix = "geofilter"
+
+// statsdClient sends metrics to a StatsD or DogStatsD server over UDP.
+// Metrics are dropped when they can not be sent.
+type statsdClient struct {
+	addr string
+	// lock guards conn, which is closed while requests may still be counted
+	lock sync.RWMutex
+	conn net.Conn
+}
+
+// WithStatsD is used to send metrics to a StatsD server at the specified host:port over UDP.
+// Numbers of allowed, blocked, unresolved and invalid requests are sent as geofilter.requests.* counters,
+// the duration of a country lookup is sent as the geofilter.lookup timer.
+func WithStatsD(addr string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		addr = strings.TrimSpace(addr)
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, configError(ErrInvalidOption, "invalid StatsD address '%s', expected host:port", addr)
+		}
+
+		proxy.statsd = &statsdClient{addr: addr}
+		return proxy, nil
+	}
+}
+
+func (c *statsdClient) open() error {
+	conn, err := net.Dial("udp", c.addr)
+	if err != nil {
+		return errors.Wrapf(err, "can not connect to StatsD at %s", c.addr)
+	}
+
+	c.lock.Lock()
+	c.conn = conn
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *statsdClient) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func (c *statsdClient) count(name string) {
+	c.send(fmt.Sprintf("%s.%s:1|c", statsdPrefix, name))
+}
+
+func (c *statsdClient) timing(name string, d time.Duration) {
+	c.send(fmt.Sprintf("%s.%s:%.3f|ms", statsdPrefix, name, float64(d)/float64(time.Millisecond)))
+}
+
+func (c *statsdClient) send(line string) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.conn == nil {
+		return
+	}
+
+	_, _ = c.conn.Write([]byte(line))
+}
+
+// countRequest increments a request counter and sends it to StatsD when configured.
+func (p *geoProxy) countRequest(counter *uint64, name string) {
+	atomic.AddUint64(counter, 1)
+	if p.statsd != nil {
+		p.statsd.count("requests." + name)
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"net"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func listenStatsD(t *testing.T) net.PacketConn {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return conn
+}
+
+// readMetrics reads n metric lines from a StatsD listener.
+func readMetrics(t *testing.T, conn net.PacketConn, n int) []string {
+	t.Helper()
+
+	var lines []string
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(lines) < n {
+		size, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("received %v: %v", lines, err)
+		}
+		lines = append(lines, string(buf[:size]))
+	}
+
+	return lines
+}
+
+func TestStatsD(t *testing.T) {
+	listener := listenStatsD(t)
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithAllowedCountries([]string{"US"}),
+		WithStatsD(listener.LocalAddr().String()),
+	)
+
+	handler := p.Middleware()(okHandler)
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("1.1.1.1"))
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("2.2.2.2"))
+
+	var counters, timers []string
+	for _, line := range readMetrics(t, listener, 4) {
+		if strings.HasSuffix(line, "|ms") {
+			timers = append(timers, line)
+		} else {
+			counters = append(counters, line)
+		}
+	}
+	sort.Strings(counters)
+
+	expected := []string{"geofilter.requests.allowed:1|c", "geofilter.requests.blocked:1|c"}
+	if strings.Join(counters, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected counters %v, got %v", expected, counters)
+	}
+	for _, timer := range timers {
+		if !strings.HasPrefix(timer, "geofilter.lookup:") {
+			t.Errorf("unexpected timer %s", timer)
+		}
+	}
+	if len(timers) != 2 {
+		t.Errorf("expected 2 lookup timers, got %v", timers)
+	}
+}
+
+func TestStatsDCloseWhileSending(t *testing.T) {
+	listener := listenStatsD(t)
+	client := &statsdClient{addr: listener.LocalAddr().String()}
+	if err := client.open(); err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				client.count("requests.allowed")
+			}
+		}()
+	}
+	client.close()
+	wg.Wait()
+}
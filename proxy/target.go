@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// WithLazyTarget is used to respond with 503 Service Unavailable instead of 502 Bad Gateway
+// while the target host does not resolve, e.g. when the target is started after the proxy.
+func WithLazyTarget() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.lazyTarget = true
+		return proxy, nil
+	}
+}
+
+// WithStrictTarget is used to fail to start when the target host or any of the weighted
+// target hosts does not resolve. By default, the proxy only logs a warning and starts anyway.
+func WithStrictTarget() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.strictTarget = true
+		return proxy, nil
+	}
+}
+
+func validateTarget(target string) error {
+	targetUrl, err := url.Parse(target)
+	if err != nil || len(targetUrl.Scheme) == 0 || len(targetUrl.Host) == 0 {
+		return configError(ErrInvalidTarget, "URL '%s'", target)
+	}
+
+	return nil
+}
+
+// checkTarget verifies that the target hosts resolve.
+func (p *geoProxy) checkTarget() error {
+	targets := make([]string, 0, len(p.weightedTargets)+1)
+	if len(p.targetUrl) > 0 {
+		targets = append(targets, p.targetUrl)
+	}
+	for _, target := range p.weightedTargets {
+		targets = append(targets, target.url)
+	}
+
+	for _, target := range targets {
+		targetUrl, _ := url.Parse(target)
+		if _, err := p.lookupHost(targetUrl.Hostname()); err != nil {
+			if p.strictTarget {
+				return configError(ErrInvalidTarget, "Can not resolve target host '%s': %v\n", targetUrl.Hostname(), err)
+			}
+
+			p.logger.Warn("target host does not resolve yet",
+				zap.String("host", targetUrl.Hostname()),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
+
+// targetErrorStatus returns a status of a response to a request which failed to reach the target.
+func (p *geoProxy) targetErrorStatus(err error) int {
+	if p.lazyTarget {
+		if _, ok := errors.Cause(err).(*net.DNSError); ok {
+			return http.StatusServiceUnavailable
+		}
+		if opErr, ok := err.(*net.OpError); ok {
+			if _, ok := opErr.Err.(*net.DNSError); ok {
+				return http.StatusServiceUnavailable
+			}
+		}
+	}
+
+	return http.StatusBadGateway
+}
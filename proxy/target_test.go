@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// unresolvedHost is a target host which resolves to a local backend once it is marked as resolvable.
+type unresolvedHost struct {
+	backend    string
+	resolvable int32
+}
+
+func (h *unresolvedHost) lookupHost(host string) ([]string, error) {
+	if atomic.LoadInt32(&h.resolvable) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return []string{"127.0.0.1"}, nil
+}
+
+func (h *unresolvedHost) transport() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if atomic.LoadInt32(&h.resolvable) == 0 {
+			host, _, _ := net.SplitHostPort(addr)
+			return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}}
+		}
+
+		return new(net.Dialer).DialContext(ctx, network, h.backend)
+	}
+
+	return transport
+}
+
+func newUnresolvedTarget(t *testing.T, strict bool, lazy bool, weighted bool) (*geoProxy, *unresolvedHost, error) {
+	t.Helper()
+
+	backend := httptest.NewServer(okHandler)
+	t.Cleanup(backend.Close)
+	host := &unresolvedHost{backend: strings.TrimPrefix(backend.URL, "http://")}
+
+	target := "http://backend.test"
+	opts := []StartOption{
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithTransport(host.transport()),
+	}
+	if weighted {
+		target = ""
+		opts = append(opts, WithWeightedTargets(map[string]int{"http://primary.test": 1}))
+	}
+	if strict {
+		opts = append(opts, WithStrictTarget())
+	}
+	if lazy {
+		opts = append(opts, WithLazyTarget())
+	}
+
+	p, err := New(0, "", target, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.lookupHost = host.lookupHost
+
+	err = p.Open()
+	if err == nil {
+		t.Cleanup(func() {
+			_ = p.Close()
+		})
+	}
+
+	return p, host, err
+}
+
+func TestStrictTarget(t *testing.T) {
+	if _, _, err := newUnresolvedTarget(t, true, false, false); err == nil {
+		t.Error("proxy with an unresolvable strict target is started")
+	}
+	if _, _, err := newUnresolvedTarget(t, true, false, true); err == nil {
+		t.Error("proxy with an unresolvable strict weighted target is started")
+	}
+}
+
+func TestUnresolvedTarget(t *testing.T) {
+	p, host, err := newUnresolvedTarget(t, false, false, false)
+	if err != nil {
+		t.Fatalf("proxy with an unresolvable target is not started: %v", err)
+	}
+
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+	if res.Code != http.StatusBadGateway {
+		t.Errorf("expected %d while the target does not resolve, got %d", http.StatusBadGateway, res.Code)
+	}
+
+	atomic.StoreInt32(&host.resolvable, 1)
+	res = httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+	if res.Code != http.StatusOK {
+		t.Errorf("request is not proxied once the target resolves, got %d", res.Code)
+	}
+}
+
+func TestLazyTarget(t *testing.T) {
+	p, host, err := newUnresolvedTarget(t, false, true, false)
+	if err != nil {
+		t.Fatalf("proxy with a lazy target is not started: %v", err)
+	}
+
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d while the target does not resolve, got %d", http.StatusServiceUnavailable, res.Code)
+	}
+
+	atomic.StoreInt32(&host.resolvable, 1)
+	res = httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+	if res.Code != http.StatusOK {
+		t.Errorf("request is not proxied once the target resolves, got %d", res.Code)
+	}
+}
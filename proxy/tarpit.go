@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"time"
+)
+
+type tarpit struct {
+	delay time.Duration
+	slots chan struct{}
+}
+
+// WithTarpit is used to delay responses to blocked requests to waste resources of scrapers.
+// At most maxConnections requests are delayed at the same time, the rest are blocked immediately.
+// The delay must be shorter than the server write timeout, otherwise the server would drop delayed responses.
+func WithTarpit(delay time.Duration, maxConnections int) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if delay <= 0 {
+			return nil, configError(ErrInvalidOption, "tarpit delay must be positive")
+		}
+
+		if maxConnections <= 0 {
+			return nil, configError(ErrInvalidOption, "maximum number of tarpitted connections must be positive")
+		}
+
+		proxy.tarpit = &tarpit{
+			delay: delay,
+			slots: make(chan struct{}, maxConnections),
+		}
+		return proxy, nil
+	}
+}
+
+// wait holds a request for the tarpit delay if there is a free slot.
+func (t *tarpit) wait(ctx context.Context, clock Clock) {
+	select {
+	case t.slots <- struct{}{}:
+	default:
+		return
+	}
+	defer func() {
+		<-t.slots
+	}()
+
+	timer := clock.NewTimer(t.delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTarpit(t *testing.T) {
+	clock := newFakeClock()
+	tarpit := &tarpit{delay: time.Minute, slots: make(chan struct{}, 1)}
+
+	done := make(chan struct{})
+	go func() {
+		tarpit.wait(context.Background(), clock)
+		close(done)
+	}()
+	clock.waitForTimers(t, 1)
+
+	// the only slot is taken, so the next request is not delayed
+	tarpit.wait(context.Background(), clock)
+
+	clock.Advance(time.Minute - time.Second)
+	select {
+	case <-done:
+		t.Fatal("request is released before the delay")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	<-done
+
+	if len(tarpit.slots) != 0 {
+		t.Errorf("tarpit slot is not released")
+	}
+}
+
+func TestTarpitCanceled(t *testing.T) {
+	clock := newFakeClock()
+	tarpit := &tarpit{delay: time.Minute, slots: make(chan struct{}, 1)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tarpit.wait(ctx, clock)
+		close(done)
+	}()
+	clock.waitForTimers(t, 1)
+
+	cancel()
+	<-done
+
+	if n := clock.pendingTimers(); n != 0 {
+		t.Errorf("timer of a canceled request is not stopped, %d are pending", n)
+	}
+}
+
+func TestTarpitWriteTimeout(t *testing.T) {
+	_, err := New(0, "", "",
+		WithServerTimeouts(time.Second, time.Second, 30*time.Second, time.Second),
+		WithTarpit(time.Minute, 10),
+	)
+	if err == nil {
+		t.Error("tarpit delay longer than the write timeout is accepted")
+	}
+
+	_, err = New(0, "", "",
+		WithServerTimeouts(time.Second, time.Second, 0, time.Second),
+		WithTarpit(time.Minute, 10),
+	)
+	if err != nil {
+		t.Errorf("tarpit is rejected without a write timeout: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+	"go.uber.org/zap"
+)
+
+const hostingUserType = "hosting"
+
+// anonymizerDbTypes are prefixes of the database types which have the anonymous proxy trait in country records.
+var anonymizerDbTypes = []string{"GeoIP2-Country", "GeoIP2-City", "GeoIP2-Precision", "GeoIP2-Enterprise"}
+
+// WithBlockAnonymous is used to configure a proxy to block requests coming from anonymous proxies.
+// It requires a GeoIP2 Country, City or Enterprise database, the option is disabled with a warning
+// on other databases. The separate GeoIP2 Anonymous IP database is not supported.
+func WithBlockAnonymous() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.blockAnonymous = true
+		return proxy, nil
+	}
+}
+
+// WithBlockHosting is used to configure a proxy to block requests coming from hosting providers.
+// It requires a GeoIP2 Enterprise database, the option is disabled with a warning on other databases.
+func WithBlockHosting() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.blockHosting = true
+		return proxy, nil
+	}
+}
+
+func isEnterpriseDb(db *geoip2.Reader) bool {
+	return strings.Contains(db.Metadata().DatabaseType, "Enterprise")
+}
+
+func hasAnonymizerTraits(db *geoip2.Reader) bool {
+	dbType := db.Metadata().DatabaseType
+	for _, prefix := range anonymizerDbTypes {
+		if strings.HasPrefix(dbType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkTraitsSupport disables trait filters which are not supported by the loaded database.
+func (p *geoProxy) checkTraitsSupport() {
+	if p.db == nil {
+		// a custom resolver provides no hosting provider data
+		p.blockHosting = false
+		return
+	}
+
+	for _, db := range []*geoip2.Reader{p.db, p.ipv6Db} {
+		if db == nil {
+			continue
+		}
+		dbType := db.Metadata().DatabaseType
+
+		if p.blockAnonymous && !hasAnonymizerTraits(db) {
+			p.logger.Warn("database has no anonymous proxy data, anonymous proxies will not be blocked",
+				zap.String("type", dbType),
+			)
+			p.blockAnonymous = false
+		}
+
+		if p.blockHosting && !isEnterpriseDb(db) {
+			p.logger.Warn("database has no hosting provider data, hosting providers will not be blocked",
+				zap.String("type", dbType),
+			)
+			p.blockHosting = false
+		}
+	}
+}
+
+func (p *geoProxy) isHosting(ip net.IP) bool {
+	p.dbLock.RLock()
+	defer p.dbLock.RUnlock()
+
+	record, err := p.dbFor(ip).Enterprise(ip)
+	if err != nil {
+		return false
+	}
+
+	return record.Traits.UserType == hostingUserType
+}
+
+// blockedTrait returns a name of a trait the request is blocked by or an empty string.
+func (p *geoProxy) blockedTrait(ip net.IP, country *geoip2.Country) string {
+	if p.blockAnonymous && country.Traits.IsAnonymousProxy {
+		return "anonymous proxy"
+	}
+
+	if p.blockHosting && p.isHosting(ip) {
+		return "hosting provider"
+	}
+
+	return ""
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+func TestBlockAnonymous(t *testing.T) {
+	resolve := func(ip net.IP) (*geoip2.Country, error) {
+		record := &geoip2.Country{}
+		record.Country.IsoCode = "US"
+		record.Traits.IsAnonymousProxy = ip.Equal(net.ParseIP("10.0.0.1"))
+		return record, nil
+	}
+
+	p := openTestProxy(t,
+		WithResolver(resolve),
+		WithAllowedCountries([]string{"US"}),
+		WithBlockAnonymous(),
+		WithBlockHosting(),
+	)
+
+	if !p.blockAnonymous {
+		t.Error("anonymous proxy filter is disabled for a resolver")
+	}
+	if p.blockHosting {
+		t.Error("hosting provider filter is enabled without an enterprise database")
+	}
+
+	tests := []struct {
+		addr     string
+		expected int
+	}{
+		{"10.0.0.1", http.StatusForbidden},
+		{"10.0.0.2", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		p.Middleware()(okHandler).ServeHTTP(res, newTestRequest(test.addr))
+
+		if res.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.addr, test.expected, res.Code)
+		}
+	}
+}
+
+func TestTraitBlockDecisionCountry(t *testing.T) {
+	resolve := func(ip net.IP) (*geoip2.Country, error) {
+		record := &geoip2.Country{}
+		record.Country.IsoCode = "XK"
+		record.RegisteredCountry.IsoCode = "XK"
+		record.Traits.IsAnonymousProxy = true
+		return record, nil
+	}
+
+	p := openTestProxy(t,
+		WithResolver(resolve),
+		WithBlockAnonymous(),
+		WithCountryMapper(func(isoCode string) string {
+			if isoCode == "XK" {
+				return "RS"
+			}
+			return isoCode
+		}),
+	)
+
+	req := WithDecisionRecorder(newTestRequest("1.1.1.1"))
+	res := httptest.NewRecorder()
+	p.Middleware()(okHandler).ServeHTTP(res, req)
+
+	decision, ok := DecisionFromContext(req.Context())
+	if !ok {
+		t.Fatal("decision is not recorded")
+	}
+	if res.Code != http.StatusForbidden || decision.Allowed {
+		t.Errorf("anonymous proxy is not blocked, got %d", res.Code)
+	}
+	if decision.Country != "RS" {
+		t.Errorf("expected the mapped country RS in the decision, got '%s'", decision.Country)
+	}
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithTransport is used to send requests to the target with the specified transport
+// instead of http.DefaultTransport, e.g. to tune its connection pool or to capture
+// requests in tests without a running target.
+func WithTransport(transport http.RoundTripper) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if transport == nil {
+			return nil, configError(ErrInvalidOption, "transport is not specified")
+		}
+
+		proxy.baseTransport = transport
+		return proxy, nil
+	}
+}
+
+// NewTransport returns a copy of http.DefaultTransport with the specified connection pool settings,
+// zero values keep the defaults. maxIdleConns limits idle connections both in total and per host,
+// since requests are usually sent to a single target.
+func NewTransport(maxIdleConns, maxConnsPerHost int, idleTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if maxIdleConns > 0 {
+		transport.MaxIdleConns = maxIdleConns
+		transport.MaxIdleConnsPerHost = maxIdleConns
+	}
+	if maxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = maxConnsPerHost
+	}
+	if idleTimeout > 0 {
+		transport.IdleConnTimeout = idleTimeout
+	}
+
+	return transport
+}
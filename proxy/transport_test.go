@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTransport answers requests without a target and counts them.
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestTransport(t *testing.T) {
+	transport := &countingTransport{}
+	p, err := New(0, "", "http://backend",
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithTransport(transport),
+		WithBackendRetries(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	res := httptest.NewRecorder()
+	p.Handler().ServeHTTP(res, newTestRequest("1.1.1.1"))
+
+	if res.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, res.Code)
+	}
+	if n := atomic.LoadInt32(&transport.requests); n != 1 {
+		t.Errorf("expected the request to be sent with the configured transport, got %d requests", n)
+	}
+
+	if _, err := New(0, "", "", WithTransport(nil)); err == nil {
+		t.Error("nil transport is accepted")
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	defaults := http.DefaultTransport.(*http.Transport)
+
+	transport := NewTransport(0, 0, 0)
+	if transport.MaxIdleConns != defaults.MaxIdleConns || transport.MaxConnsPerHost != defaults.MaxConnsPerHost ||
+		transport.IdleConnTimeout != defaults.IdleConnTimeout {
+		t.Error("zero values don't keep the defaults")
+	}
+
+	transport = NewTransport(10, 20, time.Minute)
+	if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("expected 10 idle connections, got %d in total and %d per host", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.MaxConnsPerHost != 20 {
+		t.Errorf("expected 20 connections per host, got %d", transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != time.Minute {
+		t.Errorf("expected an idle timeout of 1m, got %s", transport.IdleConnTimeout)
+	}
+	if defaults.MaxIdleConns == 10 {
+		t.Error("default transport is modified")
+	}
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import "net/http"
+
+// WithTrustedProxies is used to honor client address headers like X-Forwarded-For or CF-Connecting-IP
+// only in requests coming from the specified networks. Requests from other peers are filtered by
+// their own addresses. Without the option the headers are honored in all requests.
+func WithTrustedProxies(networks []string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(networks) == 0 {
+			return nil, configError(ErrInvalidOption, "trusted proxies are not specified")
+		}
+
+		trusted, err := parseNetworks(networks)
+		if err != nil {
+			return nil, err
+		}
+
+		proxy.trustedProxies = trusted
+		return proxy, nil
+	}
+}
+
+// isTrustedPeer reports whether headers set by a peer of a request are honored.
+func (p *geoProxy) isTrustedPeer(r *http.Request) bool {
+	if p.trustedProxies == nil {
+		return true
+	}
+
+	peer := getIP(r.RemoteAddr)
+	return peer != nil && containsIP(p.trustedProxies, peer)
+}
+
+// clientAddr returns a client address of a request, taking into account headers set by trusted proxies.
+func (p *geoProxy) clientAddr(r *http.Request) string {
+	if !p.isTrustedPeer(r) {
+		return r.RemoteAddr
+	}
+
+	return getRemoteAddr(r, p.xffSelector)
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// WithUnixSocket is used to make a proxy listen on a Unix domain socket instead of a TCP port.
+// A stale socket file left by a previous run is removed, a socket of a running process is never removed. Client addresses are not available
+// on a Unix socket, so they are taken from the X-Forwarded-For or X-Real-Ip headers.
+func WithUnixSocket(path string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(path) == 0 {
+			return nil, configError(ErrInvalidOption, "unix socket path is not specified")
+		}
+
+		proxy.unixSocket = path
+		return proxy, nil
+	}
+}
+
+// listenAddr returns the address a proxy listens on.
+func (p *geoProxy) listenAddr() string {
+	if len(p.unixSocket) > 0 {
+		return "unix:" + p.unixSocket
+	}
+
+	return fmt.Sprintf(":%d", p.port)
+}
+
+func (p *geoProxy) listen() (net.Listener, error) {
+	if len(p.unixSocket) == 0 {
+		return net.Listen("tcp", fmt.Sprintf(":%d", p.port))
+	}
+
+	if info, err := os.Stat(p.unixSocket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, errors.Errorf("'%s' exists and is not a socket", p.unixSocket)
+		}
+		if conn, err := net.Dial("unix", p.unixSocket); err == nil {
+			_ = conn.Close()
+			return nil, errors.Errorf("socket '%s' is in use by another process", p.unixSocket)
+		}
+		if err := os.Remove(p.unixSocket); err != nil {
+			return nil, err
+		}
+	}
+
+	return net.Listen("unix", p.unixSocket)
+}
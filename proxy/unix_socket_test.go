@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixSocketInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "proxy.sock")
+
+	running, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &geoProxy{unixSocket: path}
+	if listener, err := p.listen(); err == nil {
+		_ = listener.Close()
+		t.Fatal("socket of a running server is replaced")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("socket of a running server is removed: %v", err)
+	}
+
+	// a closed listener removes its socket file, a stale file is left by a crashed process
+	running.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = running.Close()
+
+	listener, err := p.listen()
+	if err != nil {
+		t.Fatalf("stale socket is not replaced: %v", err)
+	}
+	_ = listener.Close()
+}
@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithBlockEmptyUserAgent is used to block requests without a User-Agent header or with an empty one,
+// in addition to other filtering rules. Blocked requests are handled by the configured block action.
+func WithBlockEmptyUserAgent() StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.blockEmptyUserAgent = true
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) blockedUserAgent(req *http.Request) *blockReason {
+	if p.blockEmptyUserAgent && len(strings.TrimSpace(req.UserAgent())) == 0 {
+		return &blockReason{rule: "user agent", value: "empty"}
+	}
+
+	return nil
+}
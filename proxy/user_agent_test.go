@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBlockEmptyUserAgent(t *testing.T) {
+	p := openTestProxy(t,
+		WithResolver(countries(map[string]string{"1.1.1.1": "US", "2.2.2.2": "RU"})),
+		WithBlockedCountries([]string{"RU"}),
+		WithBlockEmptyUserAgent(),
+	)
+	handler := p.Middleware()(okHandler)
+
+	tests := []struct {
+		addr      string
+		userAgent string
+		status    int
+	}{
+		{"1.1.1.1", "Mozilla/5.0", http.StatusOK},
+		{"1.1.1.1", "", http.StatusForbidden},
+		{"1.1.1.1", "   ", http.StatusForbidden},
+		// geo rules are still applied to requests with a user agent
+		{"2.2.2.2", "Mozilla/5.0", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		req := newTestRequest(test.addr)
+		if len(test.userAgent) > 0 {
+			req.Header.Set("User-Agent", test.userAgent)
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		if res.Code != test.status {
+			t.Errorf("%s with user agent '%s': expected %d, got %d", test.addr, test.userAgent, test.status, res.Code)
+		}
+	}
+}
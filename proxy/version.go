@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// VersionInfo describes a build of the proxy.
+type VersionInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+}
+
+// WithVersion is used to report a build version of the proxy in the startup log.
+func WithVersion(info VersionInfo) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		proxy.version = &info
+		return proxy, nil
+	}
+}
+
+// WithVersionEndpoint is used to serve the version set by WithVersion as JSON on the specified path.
+func WithVersionEndpoint(path string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if !strings.HasPrefix(path, "/") {
+			return nil, configError(ErrInvalidOption, "invalid version endpoint path '%s'", path)
+		}
+
+		proxy.versionPath = path
+		return proxy, nil
+	}
+}
+
+func (p *geoProxy) versionHandler(res http.ResponseWriter, _ *http.Request) {
+	info := VersionInfo{}
+	if p.version != nil {
+		info = *p.version
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(res).Encode(info)
+}
+
+func (p *geoProxy) versionFields() []zap.Field {
+	if p.version == nil {
+		return nil
+	}
+
+	return []zap.Field{
+		zap.String("version", p.version.Version),
+		zap.String("commit", p.version.Commit),
+		zap.String("date", p.version.Date),
+	}
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	info := VersionInfo{Version: "1.2.3", Commit: "abcdef", Date: "2020-03-01"}
+	p := openTestProxy(t,
+		WithResolver(countries(nil)),
+		WithVersion(info),
+		WithVersionEndpoint("/version"),
+	)
+	p.setupAdminEndpoints()
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	res := httptest.NewRecorder()
+	p.mux.ServeHTTP(res, req)
+
+	if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+
+	served := VersionInfo{}
+	if err := json.NewDecoder(res.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	if served != info {
+		t.Errorf("expected %+v, got %+v", info, served)
+	}
+}
+
+func TestVersionEndpointPath(t *testing.T) {
+	if _, err := New(0, "", "", WithVersionEndpoint("version")); err == nil {
+		t.Error("relative version endpoint path is accepted")
+	}
+}
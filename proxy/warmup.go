@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// WithCacheWarmup is used to resolve addresses listed in a file, one per line, into the lookup cache
+// at startup and after each reload of a GeoIP database. Lines starting with # are ignored.
+// The option enables the prefix cache.
+func WithCacheWarmup(path string) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(path) == 0 {
+			return nil, configError(ErrInvalidOption, "warmup file path is not specified")
+		}
+
+		if proxy.cache == nil {
+			proxy.cache = newPrefixCache(defaultPrefixCacheSize)
+		}
+		proxy.warmupPath = path
+		return proxy, nil
+	}
+}
+
+// warmUpCache resolves listed addresses into the lookup cache.
+func (p *geoProxy) warmUpCache() {
+	if len(p.warmupPath) == 0 || p.cache == nil {
+		return
+	}
+
+	f, err := os.Open(p.warmupPath)
+	if err != nil {
+		p.logger.Warn("failed to open cache warmup file",
+			zap.String("path", p.warmupPath),
+			zap.Error(err),
+		)
+		return
+	}
+	defer f.Close()
+
+	var resolved, skipped int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ip := getIP(line)
+		if ip == nil {
+			skipped++
+			continue
+		}
+
+		country, err := p.resolve(ip)
+		if err != nil {
+			skipped++
+			continue
+		}
+		p.cache.put(ip, country, p.cacheExpiry())
+		resolved++
+	}
+
+	if err := scanner.Err(); err != nil {
+		p.logger.Warn("failed to read cache warmup file",
+			zap.String("path", p.warmupPath),
+			zap.Error(err),
+		)
+	}
+
+	p.logger.Info("lookup cache is warmed up",
+		zap.Int("resolved", resolved),
+		zap.Int("skipped", skipped),
+	)
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+func TestCacheWarmup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geofilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "warmup.txt")
+	content := "# hottest clients\n1.1.1.1\n\n2001:db8::1\nnot an address\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var lookups int32
+	resolve := countries(map[string]string{"1.1.1.1": "US", "2001:db8::1": "US", "3.3.3.3": "US"})
+	p := openTestProxy(t,
+		WithResolver(func(ip net.IP) (*geoip2.Country, error) {
+			atomic.AddInt32(&lookups, 1)
+			return resolve(ip)
+		}),
+		WithAllowedCountries([]string{"US"}),
+		WithCacheWarmup(path),
+	)
+
+	if n := atomic.LoadInt32(&lookups); n != 2 {
+		t.Fatalf("expected 2 addresses to be resolved at startup, got %d", n)
+	}
+
+	handler := p.Middleware()(okHandler)
+	for _, addr := range []string{"1.1.1.1", "2001:db8::1"} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newTestRequest(addr))
+		if res.Code != http.StatusOK {
+			t.Errorf("expected %d for %s, got %d", http.StatusOK, addr, res.Code)
+		}
+	}
+	if n := atomic.LoadInt32(&lookups); n != 2 {
+		t.Errorf("warmed up addresses are not served from the cache, got %d lookups", n)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), newTestRequest("3.3.3.3"))
+	if n := atomic.LoadInt32(&lookups); n != 3 {
+		t.Errorf("expected an address missing in the warmup file to be resolved, got %d lookups", n)
+	}
+
+	p.afterDbReload()
+	if n := atomic.LoadInt32(&lookups); n != 5 {
+		t.Errorf("expected the cache to be warmed up after a reload, got %d lookups", n)
+	}
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http/httputil"
+	"sort"
+)
+
+type weightedTarget struct {
+	url    string
+	weight int
+	proxy  *httputil.ReverseProxy
+}
+
+// WithWeightedTargets is used to distribute allowed requests between several targets proportionally
+// to their weights, e.g. 90 for a primary backend and 10 for a canary one. The target passed to New is
+// not used when the option is specified.
+func WithWeightedTargets(targets map[string]int) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		if len(targets) == 0 {
+			return nil, configError(ErrInvalidTarget, "weighted targets are not specified")
+		}
+
+		weighted := make([]*weightedTarget, 0, len(targets))
+		total := 0
+		for target, weight := range targets {
+			if err := validateTarget(target); err != nil {
+				return nil, err
+			}
+			if weight < 0 {
+				return nil, configError(ErrInvalidTarget, "weight of target '%s' must not be negative", target)
+			}
+
+			weighted = append(weighted, &weightedTarget{url: target, weight: weight})
+			total += weight
+		}
+
+		if total == 0 {
+			return nil, configError(ErrInvalidTarget, "at least one target must have a positive weight")
+		}
+
+		// a stable order keeps the distribution independent of map iteration
+		sort.Slice(weighted, func(i, j int) bool {
+			return weighted[i].url < weighted[j].url
+		})
+
+		proxy.weightedTargets = weighted
+		proxy.totalWeight = total
+		return proxy, nil
+	}
+}
+
+// pickReverseProxy returns a reverse proxy of a target chosen for a request.
+func (p *geoProxy) pickReverseProxy() *httputil.ReverseProxy {
+	if len(p.weightedTargets) == 0 {
+		return p.reverseProxy
+	}
+
+	n := p.randomIntn(p.totalWeight)
+	for _, target := range p.weightedTargets {
+		if n < target.weight {
+			return target.proxy
+		}
+		n -= target.weight
+	}
+
+	return p.weightedTargets[len(p.weightedTargets)-1].proxy
+}
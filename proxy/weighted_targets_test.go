@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWeightedTargets(t *testing.T) {
+	hits := make(map[string]int)
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+			hits[name]++
+		}))
+	}
+	primary, canary, disabled := newBackend("primary"), newBackend("canary"), newBackend("disabled")
+	defer primary.Close()
+	defer canary.Close()
+	defer disabled.Close()
+
+	p, err := New(0, "", "",
+		WithQuiet(),
+		WithResolver(countries(map[string]string{"1.1.1.1": "US"})),
+		WithWeightedTargets(map[string]int{primary.URL: 90, canary.URL: 10, disabled.URL: 0}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.randomIntn = rand.New(rand.NewSource(1)).Intn
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	const requests = 1000
+	for i := 0; i < requests; i++ {
+		p.Handler().ServeHTTP(httptest.NewRecorder(), newTestRequest("1.1.1.1"))
+	}
+
+	if hits["primary"]+hits["canary"] != requests {
+		t.Fatalf("expected %d proxied requests, got %v", requests, hits)
+	}
+	if hits["canary"] < 70 || hits["canary"] > 130 {
+		t.Errorf("expected about 10%% of requests to reach the canary, got %v", hits)
+	}
+	if hits["disabled"] > 0 {
+		t.Errorf("target with zero weight is used: %v", hits)
+	}
+}
+
+func TestWeightedTargetsValidation(t *testing.T) {
+	invalid := []map[string]int{
+		nil,
+		{"http://primary": -1, "http://canary": 10},
+		{"http://primary": 0},
+		{"primary": 10},
+	}
+
+	for _, targets := range invalid {
+		if _, err := New(0, "", "", WithWeightedTargets(targets)); err == nil {
+			t.Errorf("invalid weighted targets %v are accepted", targets)
+		}
+	}
+}
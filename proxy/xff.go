@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+type xffKind int
+
+const (
+	xffLeftmost xffKind = iota + 1
+	xffRightmostTrusted
+	xffIndex
+)
+
+// XFFStrategy selects a client address from a chain of addresses in the X-Forwarded-For header.
+type XFFStrategy struct {
+	kind    xffKind
+	index   int
+	trusted []string
+}
+
+// XFFLeftmost selects the leftmost address, i.e. the address of the original client as reported by the first proxy.
+func XFFLeftmost() XFFStrategy {
+	return XFFStrategy{kind: xffLeftmost}
+}
+
+// XFFRightmostTrusted selects the rightmost address which does not belong to trusted proxies.
+// Trusted proxies are specified in the notations accepted by WithAllowedNetworks.
+// The leftmost address is selected when all addresses are trusted.
+func XFFRightmostTrusted(trustedProxies []string) XFFStrategy {
+	return XFFStrategy{kind: xffRightmostTrusted, trusted: trustedProxies}
+}
+
+// XFFIndex selects an address at a fixed position. Positions are counted from 0 at the left,
+// negative positions are counted from the right, e.g. -1 selects the rightmost address.
+// The closest address is selected when the chain is shorter than the position requires.
+func XFFIndex(index int) XFFStrategy {
+	return XFFStrategy{kind: xffIndex, index: index}
+}
+
+// WithXFFStrategy is used to configure which address of the X-Forwarded-For header is treated as a client address.
+// Without the option the header is expected to contain a single address.
+func WithXFFStrategy(strategy XFFStrategy) StartOption {
+	return func(proxy *geoProxy) (*geoProxy, error) {
+		selector := xffSelector{strategy: strategy}
+
+		switch strategy.kind {
+		case xffLeftmost, xffIndex:
+		case xffRightmostTrusted:
+			if len(strategy.trusted) == 0 {
+				return nil, configError(ErrInvalidOption, "trusted proxies are not specified")
+			}
+
+			trusted, err := parseNetworks(strategy.trusted)
+			if err != nil {
+				return nil, err
+			}
+			selector.trusted = trusted
+		default:
+			return nil, configError(ErrInvalidOption, "X-Forwarded-For strategy is not specified")
+		}
+
+		proxy.xffSelector = &selector
+		return proxy, nil
+	}
+}
+
+type xffSelector struct {
+	strategy XFFStrategy
+	trusted  []*net.IPNet
+}
+
+// selectAddr selects a client address from values of X-Forwarded-For headers.
+func (s *xffSelector) selectAddr(values []string) string {
+	var chain []string
+	for _, value := range values {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); len(addr) > 0 {
+				chain = append(chain, addr)
+			}
+		}
+	}
+
+	if len(chain) == 0 {
+		return ""
+	}
+
+	switch s.strategy.kind {
+	case xffRightmostTrusted:
+		for i := len(chain) - 1; i >= 0; i-- {
+			if ip := getIP(chain[i]); ip == nil || !containsIP(s.trusted, ip) {
+				return chain[i]
+			}
+		}
+		return chain[0]
+	case xffIndex:
+		i := s.strategy.index
+		if i < 0 {
+			i += len(chain)
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(chain) {
+			i = len(chain) - 1
+		}
+		return chain[i]
+	default:
+		return chain[0]
+	}
+}
+
+func forwardedFor(r *http.Request, selector *xffSelector) string {
+	if selector == nil {
+		return r.Header.Get("X-Forwarded-For")
+	}
+
+	return selector.selectAddr(r.Header.Values("X-Forwarded-For"))
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import "testing"
+
+func TestXFFStrategies(t *testing.T) {
+	// a client spoofs the first address, the next ones are appended by the CDN and the load balancer
+	header := []string{"6.6.6.6, 1.1.1.1", "203.0.113.10, 10.0.0.1"}
+	trusted := []string{"203.0.113.0/24", "10.0.0.0/8"}
+
+	tests := []struct {
+		name     string
+		strategy XFFStrategy
+		values   []string
+		expected string
+	}{
+		{"leftmost", XFFLeftmost(), header, "6.6.6.6"},
+		{"rightmost trusted", XFFRightmostTrusted(trusted), header, "1.1.1.1"},
+		{"all trusted", XFFRightmostTrusted(trusted), []string{"203.0.113.10, 10.0.0.1"}, "203.0.113.10"},
+		{"index", XFFIndex(1), header, "1.1.1.1"},
+		{"negative index", XFFIndex(-2), header, "203.0.113.10"},
+		{"rightmost", XFFIndex(-1), header, "10.0.0.1"},
+		{"index beyond chain", XFFIndex(10), header, "10.0.0.1"},
+		{"negative index beyond chain", XFFIndex(-10), header, "6.6.6.6"},
+		{"empty header", XFFLeftmost(), []string{" , "}, ""},
+	}
+
+	for _, test := range tests {
+		p, err := WithXFFStrategy(test.strategy)(&geoProxy{})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if addr := p.xffSelector.selectAddr(test.values); addr != test.expected {
+			t.Errorf("%s: expected '%s', got '%s'", test.name, test.expected, addr)
+		}
+	}
+}
+
+func TestXFFStrategyErrors(t *testing.T) {
+	for _, strategy := range []XFFStrategy{{}, XFFRightmostTrusted(nil), XFFRightmostTrusted([]string{"invalid"})} {
+		if _, err := WithXFFStrategy(strategy)(&geoProxy{}); err == nil {
+			t.Errorf("invalid strategy %+v is accepted", strategy)
+		}
+	}
+}